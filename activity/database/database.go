@@ -3,18 +3,16 @@ package database
 import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"log"
 )
 
 var (
 	DBConn *gorm.DB
 )
 
-// init function runs before everything, so we will create our database here
-func init() {
+// Connect opens the database at path, the models use it from then on. The server connects
+// to its own database at start-up, the tests to one of their own.
+func Connect(path string) error {
 	var err error
-	DBConn, err = gorm.Open(sqlite.Open("activity.db"), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Cannot connect to the database: ", err)
-	}
+	DBConn, err = gorm.Open(sqlite.Open(path), &gorm.Config{})
+	return err
 }
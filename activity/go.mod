@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/joho/godotenv v1.4.0
+	github.com/yusufatalay/SocketProgramming/reservation v0.0.0
 	gorm.io/driver/sqlite v1.4.3
 	gorm.io/gorm v1.24.2
 )
@@ -13,3 +14,6 @@ require (
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/mattn/go-sqlite3 v1.14.15 // indirect
 )
+
+// the client of the reservation server is shared with the other servers
+replace github.com/yusufatalay/SocketProgramming/reservation => ../reservation
@@ -0,0 +1,79 @@
+package helper
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// Machine readable error codes of the JSON responses, the HTML responses only carry the title.
+const (
+	ErrNotFound         = "not_found"
+	ErrMethodNotAllowed = "method_not_allowed"
+	ErrMalformedRequest = "malformed_request"
+	ErrInvalidParameter = "invalid_parameter"
+	ErrInvalidBody      = "invalid_body"
+	ErrDatabase         = "database_error"
+	ErrActivityExists   = "activity_exists"
+	ErrActivityNotFound = "activity_not_found"
+	ErrUpstream         = "upstream_error"
+)
+
+// APIResponse is the document sent to the clients which accept application/json.
+type APIResponse struct {
+	Status  int         `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Title   string      `json:"title,omitempty"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// CreateErrorResponse renders an error as JSON if the client asked for it and as an HTML page otherwise.
+func CreateErrorResponse(req *Request, servername string, statuscode int, status string, code string, title string, body string) string {
+	return createResponse(req, servername, statuscode, status, code, title, body, nil, nil)
+}
+
+// CreateSuccessResponse renders a 200 OK response, data is only sent to the JSON clients
+// since the HTML page already shows it in its body.
+func CreateSuccessResponse(req *Request, servername string, title string, body string, data interface{}) string {
+	return createResponse(req, servername, 200, "OK", "", title, body, data, nil)
+}
+
+func createResponse(req *Request, servername string, statuscode int, status string, code string, title string, body string, data interface{}, headers map[string]string) string {
+	if req == nil || !req.WantsJSON() {
+		return CreateHTTPResponseWithHeaders(servername, statuscode, status, title, body, headers)
+	}
+
+	doc, err := json.Marshal(APIResponse{
+		Status:  statuscode,
+		Error:   code,
+		Title:   title,
+		Message: strings.TrimSpace(body),
+		Data:    data,
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return CreateHTTPResponseWithHeaders(servername, 500, "Internal Server Error",
+			"Encoding Error", err.Error(), nil)
+	}
+
+	return createRawResponse(statuscode, status, "application/json", headers, string(doc))
+}
+
+// CreateJSONResponse always answers in JSON whatever the Accept header says,
+// the internal API uses it since its clients are the other servers.
+func CreateJSONResponse(statuscode int, status string, code string, message string, data interface{}) string {
+	doc, err := json.Marshal(APIResponse{
+		Status:  statuscode,
+		Error:   code,
+		Message: message,
+		Data:    data,
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		statuscode, status = 500, "Internal Server Error"
+		doc = []byte(`{"status":500,"error":"encoding_error","message":"cannot encode response"}`)
+	}
+
+	return createRawResponse(statuscode, status, "application/json", nil, string(doc))
+}
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+// HandlerFunc serves a single request, it is responsible for writing the response
+// and closing the connection.
+type HandlerFunc func(conn *net.Conn, req *Request)
+
+// Router maps exact method and path pairs to handlers.
+type Router struct {
+	servername string
+	// routes is keyed by path first and then by method
+	routes map[string]map[string]HandlerFunc
+}
+
+func NewRouter(servername string) *Router {
+	return &Router{
+		servername: servername,
+		routes:     make(map[string]map[string]HandlerFunc),
+	}
+}
+
+// Handle registers handler for the given path and every given method.
+func (router *Router) Handle(path string, handler HandlerFunc, methods ...string) {
+	if _, ok := router.routes[path]; !ok {
+		router.routes[path] = make(map[string]HandlerFunc)
+	}
+	for _, method := range methods {
+		router.routes[path][method] = handler
+	}
+}
+
+// Serve dispatches the request to its handler. Unknown paths get 404 Not Found and
+// known paths with an unregistered method get 405 Method Not Allowed with an Allow header.
+func (router *Router) Serve(conn *net.Conn, req *Request) {
+	methods, ok := router.routes[req.Path]
+	if !ok {
+		router.reply(conn, CreateErrorResponse(req, router.servername, 404, "Not Found", ErrNotFound,
+			"Not Found", fmt.Sprintf("There is no endpoint at %s", req.Path)))
+
+		return
+	}
+
+	handler, ok := methods[req.Method]
+	if !ok {
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		router.reply(conn, createResponse(req, router.servername, 405, "Method Not Allowed", ErrMethodNotAllowed,
+			"Method not supported", fmt.Sprintf("%s only supports %s", req.Path, strings.Join(allowed, ", ")),
+			nil, map[string]string{"Allow": strings.Join(allowed, ", ")}))
+
+		return
+	}
+
+	handler(conn, req)
+}
+
+func (router *Router) reply(conn *net.Conn, response string) {
+	_, err := (*conn).Write([]byte(response))
+	if err != nil {
+		log.Printf("Error: %+v", err)
+	}
+	(*conn).Close()
+}
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// route serves the request with the router and returns the response.
+func route(t *testing.T, router *Router, raw string) string {
+	t.Helper()
+
+	req, err := ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("cannot make request: %v", err)
+	}
+	server, client := net.Pipe()
+	go router.Serve(&server, req)
+	response, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("cannot read response: %v", err)
+	}
+	return string(response)
+}
+
+// served is a handler answering with its name.
+func served(name string) HandlerFunc {
+	return func(conn *net.Conn, req *Request) {
+		(*conn).Write([]byte("served " + name))
+		(*conn).Close()
+	}
+}
+
+func TestRouterServe(t *testing.T) {
+	router := NewRouter("Test")
+	router.Handle("/reserve", served("reserve"), "GET", "POST")
+	router.Handle("/reserveseries", served("reserveseries"), "POST")
+
+	tests := []struct {
+		name string
+		raw  string
+		// want is the start of the response, and wantHeader a line it should have
+		want       string
+		wantHeader string
+	}{
+		{"exact path", "GET /reserve HTTP/1.1\r\n\r\n", "served reserve", ""},
+		{"query is not part of the path", "POST /reserve?room=A101 HTTP/1.1\r\n\r\n", "served reserve", ""},
+		{"longer path is another endpoint", "POST /reserveseries HTTP/1.1\r\n\r\n", "served reserveseries", ""},
+		{"prefix is not matched", "GET /reserv HTTP/1.1\r\n\r\n", "HTTP/1.0 404 Not Found", ""},
+		{"trailing slash is not matched", "GET /reserve/ HTTP/1.1\r\n\r\n", "HTTP/1.0 404 Not Found", ""},
+		{"path is case sensitive", "GET /Reserve HTTP/1.1\r\n\r\n", "HTTP/1.0 404 Not Found", ""},
+		{"unknown path", "GET /nothing HTTP/1.1\r\n\r\n", "HTTP/1.0 404 Not Found", ""},
+		{"unknown path in JSON", "GET /nothing HTTP/1.1\r\nAccept: application/json\r\n\r\n", "HTTP/1.0 404 Not Found",
+			`"error":"not_found"`},
+		{"unregistered method", "DELETE /reserve HTTP/1.1\r\n\r\n", "HTTP/1.0 405 Method Not Allowed", "Allow: GET, POST\r\n"},
+		{"method of another endpoint", "GET /reserveseries HTTP/1.1\r\n\r\n", "HTTP/1.0 405 Method Not Allowed", "Allow: POST\r\n"},
+		{"unregistered method in JSON", "PUT /reserve HTTP/1.1\r\nAccept: application/json\r\n\r\n",
+			"HTTP/1.0 405 Method Not Allowed", `"error":"method_not_allowed"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := route(t, router, tt.raw)
+			if !strings.HasPrefix(response, tt.want) {
+				t.Errorf("got response %q, want it to start with %q", response, tt.want)
+			}
+			if !strings.Contains(response, tt.wantHeader) {
+				t.Errorf("got response %q, want it to have %q", response, tt.wantHeader)
+			}
+		})
+	}
+}
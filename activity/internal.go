@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"net"
+
+	"github.com/yusufatalay/SocketProgramming/activity/helper"
+	"github.com/yusufatalay/SocketProgramming/activity/models"
+)
+
+// The internal API is meant for the other servers, not for the browsers. It always
+// answers with a helper.APIResponse document in JSON, its data field holds:
+//
+//	GET /internal/v1/activity?name=<name>  models.Activity
+//
+// Clients should switch on the error field, the messages are for humans only.
+const internalAPIPrefix = "/internal/v1"
+
+// registerInternalAPI adds the version 1 internal endpoints to the router.
+func registerInternalAPI(router *helper.Router) {
+	router.Handle(internalAPIPrefix+"/health", internalHealth, "GET")
+	router.Handle(internalAPIPrefix+"/activity", internalGetActivity, "GET")
+}
+
+// writeInternal writes the response and closes the connection.
+func writeInternal(conn *net.Conn, response string) {
+	_, err := (*conn).Write([]byte(response))
+	if err != nil {
+		log.Printf("Error: %+v", err)
+	}
+	(*conn).Close()
+}
+
+func internalHealth(conn *net.Conn, req *helper.Request) {
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Activity Server is healthy", nil))
+}
+
+func internalGetActivity(conn *net.Conn, req *helper.Request) {
+	name := req.Query.Get("name")
+	if name == "" {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidParameter,
+			"name parameter is empty", nil))
+
+		return
+	}
+
+	exists, err := models.CheckActivity(name)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(500, "Internal Server Error", helper.ErrDatabase, err.Error(), nil))
+
+		return
+	}
+	if !exists {
+		writeInternal(conn, helper.CreateJSONResponse(404, "Not Found", helper.ErrActivityNotFound,
+			"Activity does not exists", nil))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "", models.Activity{Name: name}))
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,12 +10,18 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/yusufatalay/SocketProgramming/activity/database"
 	"github.com/yusufatalay/SocketProgramming/activity/helper"
 	"github.com/yusufatalay/SocketProgramming/activity/models"
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
 )
 
+// readTimeout is how long a client has to send its whole request.
+const readTimeout = 10 * time.Second
+
 func main() {
 	// open the config file to write this serve's information
 	err := godotenv.Load("../.env")
@@ -26,20 +32,49 @@ func main() {
 		panic(errors.New("Should only provide a port number."))
 	}
 
-	// insert this server's port number to config file
-	godotenv.Write(map[string]string{
-		"ROOMSERVERPORT":     os.Getenv("ROOMSERVERPORT"),
-		"ACTIVITYSERVERPORT": os.Args[1],
-	}, "../.env")
+	err = database.Connect("activity.db")
+	if err != nil {
+		log.Fatal("Cannot connect to the database: ", err)
+	}
+	err = models.Migrate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the reservations of the renamed activities follow them in the background
+	reservationClient = client.NewReservationClient("localhost:" + os.Getenv("RESERVATIONSERVERPORT"))
+	go retryPendingRenames(reservationClient)
+
+	// insert this server's port number to config file, keeping the other settings in it
+	config, err := godotenv.Read("../.env")
+	if err != nil {
+		log.Fatalf("cannot read project's dotenv file: %v\n", err)
+	}
+	config["ACTIVITYSERVERPORT"] = os.Args[1]
+	err = godotenv.Write(config, "../.env")
+	if err != nil {
+		log.Fatalf("cannot write project's dotenv file: %v\n", err)
+	}
 
 	// create a tcp socket that listens localhost:PORT
-	ln, err := net.Listen("tcp", "localhost:"+os.Getenv("ACTIVITYSERVERPORT"))
+	ln, err := net.Listen("tcp", "localhost:"+os.Args[1])
 	if err != nil {
 		log.Fatal(err)
 	}
 	// deferred close the connection
 	defer ln.Close()
 
+	// every endpoint is matched exactly, with the methods it supports
+	router := helper.NewRouter("Activity")
+	router.Handle("/health", health, "GET")
+	router.Handle("/add", HandleAdd, "GET", "POST")
+	router.Handle("/remove", HandleRemove, "GET", "POST")
+	router.Handle("/check", HandleCheck, "GET", "POST")
+	router.Handle("/activities", HandleActivities, "GET", "POST")
+	router.Handle("/activity", HandleActivity, "GET", "POST")
+	router.Handle("/update", HandleUpdate, "GET", "POST")
+	registerInternalAPI(router)
+
 	//	program loop
 	for {
 		// accept connection
@@ -48,46 +83,33 @@ func main() {
 			log.Fatal(err)
 		}
 		// create new thread for each connection request to handle concurrency
-		go handleConnection(conn)
+		go handleConnection(conn, router)
 	}
 }
 
-func handleConnection(conn net.Conn) {
-	// Use bufio to read the request
-	buf := make([]byte, 1024)
-	_, err := conn.Read(buf)
-	if err != nil {
-
-		return
-	}
+func handleConnection(conn net.Conn, router *helper.Router) {
+	// give slow or stuck clients a limited time to send their request
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
 
 	// Parse the request
+	req, err := helper.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		response := helper.CreateErrorResponse(nil, "Activity", 400, "Bad Request", helper.ErrMalformedRequest,
+			"Malformed Request", err.Error())
+		conn.Write([]byte(response))
+		conn.Close()
 
-	reqStr := string(buf)
-	reqParts := strings.Split(reqStr, "\n")
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-
-	if strings.HasPrefix(url, "/health") {
-		health(&conn)
-	} else if strings.HasPrefix(url, "/add") {
-		HandleAdd(&conn, reqStr)
-	} else if strings.HasPrefix(url, "/remove") {
-		HandleRemove(&conn, reqStr)
-	} else if strings.HasPrefix(url, "/check") {
-		HandleCheck(&conn, reqStr)
+		return
 	}
 
+	// use appropriate handler for the url
+	router.Serve(&conn, req)
 }
 
-func health(conn *net.Conn) {
+func health(conn *net.Conn, req *helper.Request) {
 	// just return 200 OK to let the caller know this server is active
-
-	response := "HTTP/1.0 200 OK\r\n"
-	response += "Content-Type: text/plain\r\n"
-	response += "Content-Length: " + strconv.Itoa(len([]byte(fmt.Sprintf("Activity Server is healty at port -> "+os.Getenv("ACTIVITYSERVERPORT")))))
-	response += "\r\n\r\n"
-	response += "Activity Server is healty at port -> " + os.Getenv("ACTIVITYSERVERPORT")
-	response += "\r\n"
+	response := helper.CreateSuccessResponse(req, "Activity",
+		"Healthy", "Activity Server is healty at port -> "+os.Getenv("ACTIVITYSERVERPORT"), nil)
 	_, err := (*conn).Write([]byte(response))
 	if err != nil {
 		log.Fatal(err)
@@ -97,11 +119,34 @@ func health(conn *net.Conn) {
 
 }
 
+// readActivity reads the activity name from the query params of a GET request or
+// from the json body of a POST request. It returns a ready to send error response on failure.
+func readActivity(req *helper.Request) (*models.Activity, string) {
+	body := &models.Activity{}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, body)
+		if err != nil {
+			return nil, helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+		}
+	}
+	// return error if activity name has not given
+	if body.Name == "" {
+		return nil, helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Validation Error", fmt.Sprintf("name parameter is empty %s?name=<activityname>", req.Path))
+	}
+
+	return body, ""
+}
+
 // HandleAdd will be triggerred when localhost/add has been visited
-func HandleAdd(conn *net.Conn, req string) {
+func HandleAdd(conn *net.Conn, req *helper.Request) {
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -110,93 +155,111 @@ func HandleAdd(conn *net.Conn, req string) {
 		}
 		(*conn).Close()
 	}()
-	switch method {
-	case "GET":
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
 
-		activityname := strings.Split(url, "=")[1]
-		// return error if roomname has not given
-		if activityname == "" {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Validation Error", "name parameter is empty /add?name=<activityname>")
+	body, errResponse := readActivity(req)
+	if body == nil {
+		response = errResponse
+
+		return
+	}
+
+	err := models.CreateActivity(body)
+	if err != nil {
+		if err.Error() == "Activity already exists" {
+			response = helper.CreateErrorResponse(req, "Activity", 403, "Forbidden", helper.ErrActivityExists,
+				"Validation Error", "Activity already exists")
 
 			return
-		}
-		// create a room instance with the given name
-		a := &models.Activity{
-			Name: activityname,
-		}
-		// create the room in database
-		err := models.CreateActivity(a)
+		} else {
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
-		if err != nil {
-			if err.Error() == "Activity already exists" {
-				response = helper.CreateHTTPResponse("Activity", 403, "Forbidden",
-					"Validation Error", "Activity already exists")
+			return
+		}
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-					"Database Error", err.Error())
+	}
+	response = helper.CreateSuccessResponse(req, "Activity",
+		"Succesfull", fmt.Sprintf("Activity %s successfully added to database", body.Name), body)
+}
 
-				return
-			}
+func HandleRemove(conn *net.Conn, req *helper.Request) {
 
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Fatal(err)
 		}
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s successfully added to database", activityname))
+		(*conn).Close()
+	}()
+
+	body, errResponse := readActivity(req)
+	if body == nil {
+		response = errResponse
 
 		return
+	}
 
-	case "POST":
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00")
+	err := models.RemoveActivity(body.Name)
+	if err != nil {
+		if err.Error() == "activity does not exists" {
+			response = helper.CreateErrorResponse(req, "Activity", 403, "Forbidden", helper.ErrActivityNotFound,
+				"Database Error", "Activity does not exists")
 
-		var body models.Activity
-		err := json.Unmarshal(reqBodyByte, &body)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Parser Error", err.Error())
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
 			return
 		}
-		err = models.CreateActivity(&body)
-		if err != nil {
-			if err.Error() == "Activity already exists" {
-				response = helper.CreateHTTPResponse("Activity", 403, "Forbidden",
-					"Database Error", "Activity already exists")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-					"Database Error", err.Error())
+	}
+	response = helper.CreateSuccessResponse(req, "Activity",
+		"Succesfull", fmt.Sprintf("Activity %s successfully removed from database", body.Name), body)
+}
 
-				return
-			}
+func HandleCheck(conn *net.Conn, req *helper.Request) {
 
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Fatal(err)
 		}
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s successfully added to database", body.Name))
+		(*conn).Close()
+	}()
+
+	body, errResponse := readActivity(req)
+	if body == nil {
+		response = errResponse
 
 		return
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+	}
+
+	exists, err := models.CheckActivity(body.Name)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+			"Database Error", err.Error())
 
 		return
 	}
 
+	if !exists {
+		response = helper.CreateErrorResponse(req, "Activity", 404, "Not Found", helper.ErrActivityNotFound,
+			"Database Error", "Activity does not exists")
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Activity",
+		"Succesfull", fmt.Sprintf("Activity %s exists in the database", body.Name), body)
 }
 
-func HandleRemove(conn *net.Conn, req string) {
+// HandleActivities lists the activities a page at a time, only the ones whose names contain
+// name if it is given. The next_cursor of a page asks for the one after it.
+func HandleActivities(conn *net.Conn, req *helper.Request) {
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -206,85 +269,84 @@ func HandleRemove(conn *net.Conn, req string) {
 		(*conn).Close()
 	}()
 
-	switch method {
+	var body struct {
+		Name   string `json:"activity_name"`
+		Cursor string `json:"cursor"`
+		Limit  int    `json:"limit"`
+	}
+	switch req.Method {
 	case "GET":
-
-		activityname := strings.Split(url, "=")[1]
-		// return error if roomname has not given
-		if activityname == "" {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Validation Error", "name parameter is empty /remove?name=<activityname>")
-
-			return
-		}
-
-		err := models.RemoveActivity(activityname)
-		if err != nil {
-			if err.Error() == "activity does not exists" {
-				response = helper.CreateHTTPResponse("Activity", 403, "Forbidden",
-					"Database Error", "Activity does not exists")
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+		body.Cursor = req.Query.Get("cursor")
+		if req.Query.Get("limit") != "" {
+			limit, err := strconv.Atoi(req.Query.Get("limit"))
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidParameter,
+					"Parser Error", "limit value should be a number")
 
 				return
-			} else {
-				response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-					"Database Error", err.Error())
+			}
+			body.Limit = limit
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
 
 				return
 			}
-
 		}
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s successfully removed from database", activityname))
+	}
+	if body.Limit == 0 {
+		body.Limit = models.DefaultPageSize
+	}
+	if body.Limit < 1 || body.Limit > models.MaxPageSize {
+		response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", fmt.Sprintf("limit should be between 1 and %d", models.MaxPageSize))
 
 		return
+	}
 
-	case "POST":
-		// POST request has been made, read the request body
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-
-		var body models.Activity
-		err := json.Unmarshal(reqBodyByte, &body)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
+	page, err := models.FindActivities(body.Name, body.Cursor, body.Limit)
+	if err != nil {
+		if err.Error() == "cursor is not one of this listing" {
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidParameter,
 				"Parser Error", err.Error())
 
 			return
-		}
-		err = models.RemoveActivity(body.Name)
-		if err != nil {
-			if err.Error() == "activity does not exists" {
-				response = helper.CreateHTTPResponse("Activity", 403, "Forbidden",
-					"Database Error", "Activity does not exists")
-
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-					"Database Error", err.Error())
-
-				return
-			}
+		} else {
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
+			return
 		}
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s successfully removed from database", body.Name))
-
-		return
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+	}
 
-		return
+	activitiesStr := strings.Builder{}
+	if len(page.Activities) == 0 {
+		activitiesStr.WriteString("No activities found.\n")
+	}
+	for _, activity := range page.Activities {
+		activitiesStr.WriteString(activity.Name + "\n")
 	}
+	if page.NextCursor != "" && req.Method == "GET" {
+		next := req.Query
+		next.Set("cursor", page.NextCursor)
+		activitiesStr.WriteString(fmt.Sprintf("\nNext page: %s?%s\n", req.Path, next.Encode()))
+	} else if page.NextCursor != "" {
+		activitiesStr.WriteString(fmt.Sprintf("\nNext cursor: %s\n", page.NextCursor))
+	}
+
+	response = helper.CreateSuccessResponse(req, "Activity", "Activities are listed below", activitiesStr.String(), page)
 }
 
-func HandleCheck(conn *net.Conn, req string) {
+// HandleActivity shows the activity with the given name.
+func HandleActivity(conn *net.Conn, req *helper.Request) {
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -294,80 +356,103 @@ func HandleCheck(conn *net.Conn, req string) {
 		(*conn).Close()
 	}()
 
-	switch method {
-	case "GET":
+	body, errResponse := readActivity(req)
+	if body == nil {
+		response = errResponse
 
-		activityname := strings.Split(url, "=")[1]
-		// return error if roomname has not given
-		if activityname == "" {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Validation Error", "name parameter is empty /check?name=<activityname>")
+		return
+	}
 
-			return
-		}
+	exists, err := models.CheckActivity(body.Name)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+			"Database Error", err.Error())
 
-		exists, err := models.CheckActivity(activityname)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Database Error", err.Error())
+		return
+	}
+	if !exists {
+		response = helper.CreateErrorResponse(req, "Activity", 404, "Not Found", helper.ErrActivityNotFound,
+			"Database Error", "Activity does not exists")
 
-			return
+		return
+	}
 
-		}
+	response = helper.CreateSuccessResponse(req, "Activity",
+		"Activity "+body.Name, fmt.Sprintf("Activity: %s", body.Name), body)
+}
 
-		if !exists {
-			response = helper.CreateHTTPResponse("Activity", 404, "Not Found",
-				"Database Error", "Activity does not exists")
+// HandleUpdate renames an activity. The rename is saved as pending along with it and sent to
+// the reservation server, which renames the activity's reservations. When the reservation server
+// cannot be reached, the activity keeps its new name and the rename is sent again in the
+// background until the reservations follow.
+func HandleUpdate(conn *net.Conn, req *helper.Request) {
 
-			return
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
 		}
+		(*conn).Close()
+	}()
 
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s exists in the database", activityname))
-
-		return
-
+	var body struct {
+		Name    string `json:"activity_name"`
+		NewName string `json:"new_name"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+		body.NewName = req.Query.Get("newname")
 	case "POST":
-		// POST request has been made, read the request body
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-
-		var body models.Activity
-		err := json.Unmarshal(reqBodyByte, &body)
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidBody,
 				"Parser Error", err.Error())
 
 			return
 		}
+	}
+	// return error if the names have not given
+	if body.Name == "" || body.NewName == "" {
+		response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Validation Error", fmt.Sprintf("name or newname parameter is empty %s?name=<activityname>&newname=<newname>", req.Path))
 
-		exists, err := models.CheckActivity(body.Name)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-				"Database Error", err.Error())
+		return
+	}
 
-			return
+	err := models.RenameActivity(body.Name, body.NewName)
+	if err != nil {
+		if err.Error() == "activity does not exists" {
+			response = helper.CreateErrorResponse(req, "Activity", 404, "Not Found", helper.ErrActivityNotFound,
+				"Database Error", "Activity does not exists")
 
-		}
+			return
+		} else if err.Error() == "Activity already exists" {
+			response = helper.CreateErrorResponse(req, "Activity", 403, "Forbidden", helper.ErrActivityExists,
+				"Validation Error", fmt.Sprintf("Activity %s already exists", body.NewName))
 
-		if !exists {
-			response = helper.CreateHTTPResponse("Activity", 404, "Not Found",
-				"Database Error", "Activity does not exists")
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Activity", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
 			return
 		}
+	}
 
-		response = helper.CreateHTTPResponse("Activity", 200, "OK",
-			"Succesfull", fmt.Sprintf("Activity %s exists in the database", body.Name))
-
-		return
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Activity", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+	err = sendPendingRenames(reservationClient)
+	if err != nil {
+		log.Printf("Error: cannot rename the reservations of %s yet, trying again later: %+v", body.NewName, err)
+		response = helper.CreateSuccessResponse(req, "Activity", "Succesfull",
+			fmt.Sprintf("Activity %s successfully renamed to %s, its reservations will be renamed once the reservation server can be reached",
+				body.Name, body.NewName), models.Activity{Name: body.NewName})
 
 		return
 	}
 
+	response = helper.CreateSuccessResponse(req, "Activity", "Succesfull",
+		fmt.Sprintf("Activity %s successfully renamed to %s", body.Name, body.NewName), models.Activity{Name: body.NewName})
 }
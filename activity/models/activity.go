@@ -1,13 +1,21 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
 	"log"
+	"strings"
 
 	"github.com/yusufatalay/SocketProgramming/activity/database"
 	"gorm.io/gorm"
 )
 
+// DefaultPageSize and MaxPageSize bound how many activities a page of the listing holds.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 // A room only has a unique name on its own
 // how-ever it also has a "Has Many" relationship with the Reservations
 type Activity struct {
@@ -76,4 +84,86 @@ func CheckActivity(name string) (bool, error) {
 	}
 
 	return exists, nil
-}
\ No newline at end of file
+}
+
+// RenameActivity gives the activity a new name, which no other activity may have. The rename
+// is saved as pending too, until the reservation server renamed the activity's reservations.
+func RenameActivity(name string, newname string) error {
+	if newname == "" {
+		return errors.New("activity name cannot be empty")
+	}
+
+	return database.DBConn.Transaction(func(tx *gorm.DB) error {
+		var exists bool
+		err := tx.Model(Activity{}).Select("count(*) > 0").Where("name = ?", name).Find(&exists).Error
+		if err != nil {
+			log.Printf("Error: %+v", err)
+			return err
+		}
+		if !exists {
+			return errors.New("activity does not exists")
+		}
+
+		err = tx.Model(Activity{}).Select("count(*) > 0").Where("name = ?", newname).Find(&exists).Error
+		if err != nil {
+			log.Printf("Error: %+v", err)
+			return err
+		}
+		if exists {
+			return errors.New("Activity already exists")
+		}
+
+		err = tx.Model(Activity{}).Where("name = ?", name).Update("name", newname).Error
+		if err != nil {
+			log.Printf("Error: %+v", err)
+			return err
+		}
+
+		err = tx.Create(&PendingRename{Name: name, NewName: newname}).Error
+		if err != nil {
+			log.Printf("Error: %+v", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// ActivityPage is a page of the activities ordered by their names, NextCursor is empty on the
+// last page.
+type ActivityPage struct {
+	Activities []Activity `json:"activities"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// FindActivities returns a page of the activities whose names contain the given text, an empty
+// text matches every activity. Cursor is the NextCursor of the previous page.
+func FindActivities(name string, cursor string, limit int) (*ActivityPage, error) {
+	db := database.DBConn.Order("name")
+	if name != "" {
+		// the wildcards of LIKE are taken as they are
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(name)
+		db = db.Where(`name LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+	}
+	if cursor != "" {
+		last, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, errors.New("cursor is not one of this listing")
+		}
+		db = db.Where("name > ?", string(last))
+	}
+
+	// one more than the page tells if there is a next page
+	activities := []Activity{}
+	err := db.Limit(limit + 1).Find(&activities).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+
+	page := &ActivityPage{Activities: activities}
+	if len(activities) > limit {
+		page.Activities = activities[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Activities[limit-1].Name))
+	}
+	return page, nil
+}
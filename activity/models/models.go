@@ -1,14 +1,16 @@
 package models
 
 import (
+	"fmt"
+
 	"github.com/yusufatalay/SocketProgramming/activity/database"
-	"log"
 )
 
-func init() {
-	err := database.DBConn.AutoMigrate(&Activity{})
+// Migrate brings the tables of the connected database up to date with the models.
+func Migrate() error {
+	err := database.DBConn.AutoMigrate(&Activity{}, &PendingRename{})
 	if err != nil {
-		log.Fatalf("Cannot migrate models: %s", err.Error())
+		return fmt.Errorf("cannot migrate models: %w", err)
 	}
-
+	return nil
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/activity/database"
+)
+
+// PendingRename is a rename of an activity that the reservation server has not made for the
+// reservations of the activity yet. It is saved along with the rename and forgotten once the
+// reservation server made it too.
+type PendingRename struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"not null"`
+	NewName   string `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// GetPendingRenames returns the pending renames in the order they were made.
+func GetPendingRenames() ([]PendingRename, error) {
+	renames := []PendingRename{}
+	err := database.DBConn.Order("id").Find(&renames).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return renames, nil
+}
+
+// ForgetPendingRename forgets the rename once the reservation server made it.
+func ForgetPendingRename(rename *PendingRename) error {
+	err := database.DBConn.Delete(&PendingRename{}, rename.ID).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
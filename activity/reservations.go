@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/activity/models"
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+)
+
+// reservationTimeout bounds a call to the reservation server.
+const reservationTimeout = 5 * time.Second
+
+// renameRetryInterval is how often the renames the reservation server missed are sent again.
+const renameRetryInterval = 30 * time.Second
+
+// reservationRenamer renames the reservations of an activity, it is the reservation server's
+// client.
+type reservationRenamer interface {
+	RenameActivity(ctx context.Context, name string, newname string) (int64, error)
+}
+
+// reservationClient calls the reservation server at the port in the config file.
+var reservationClient reservationRenamer
+
+// renameLock keeps the pending renames from being sent by two goroutines at once, they have to
+// reach the reservation server in the order they were made.
+var renameLock sync.Mutex
+
+// sendPendingRenames asks the reservation server to move the reservations of the renamed
+// activities to their new names, in the order the activities were renamed, and forgets the
+// renames it made. It stops at the first one that fails, the later ones wait for it. Renaming
+// is idempotent there, so a rename whose answer was lost is simply sent again.
+func sendPendingRenames(server reservationRenamer) error {
+	renameLock.Lock()
+	defer renameLock.Unlock()
+
+	renames, err := models.GetPendingRenames()
+	if err != nil {
+		return err
+	}
+	for i := range renames {
+		ctx, cancel := context.WithTimeout(context.Background(), reservationTimeout)
+		_, err = server.RenameActivity(ctx, renames[i].Name, renames[i].NewName)
+		cancel()
+		if errors.Is(err, client.ErrBadRequest) {
+			// it is refused every time it is sent, the later ones should not wait for it
+			log.Printf("Error: reservation server refused to rename %s to %s: %+v", renames[i].Name, renames[i].NewName, err)
+		} else if err != nil {
+			return err
+		}
+
+		err = models.ForgetPendingRename(&renames[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryPendingRenames sends the pending renames every renameRetryInterval, including the ones
+// left by a crash and the ones made while the reservation server could not be reached.
+func retryPendingRenames(server reservationRenamer) {
+	for {
+		err := sendPendingRenames(server)
+		if err != nil {
+			log.Printf("Error: cannot rename the reservations yet, trying again later: %+v", err)
+		}
+		time.Sleep(renameRetryInterval)
+	}
+}
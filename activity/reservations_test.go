@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/activity/database"
+	"github.com/yusufatalay/SocketProgramming/activity/helper"
+	"github.com/yusufatalay/SocketProgramming/activity/models"
+
+	"gorm.io/gorm/logger"
+)
+
+// useTestDatabase points the models at an empty database of their own for the test.
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+
+	err := database.Connect(filepath.Join(t.TempDir(), "activity.db"))
+	if err != nil {
+		t.Fatalf("cannot open test database: %v", err)
+	}
+	database.DBConn.Logger = logger.Default.LogMode(logger.Silent)
+	err = models.Migrate()
+	if err != nil {
+		t.Fatalf("cannot migrate test database: %v", err)
+	}
+
+	db := database.DBConn
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// fakeReservations is a reservation server that records the renames it made, or fails them
+// with err while it cannot be reached.
+type fakeReservations struct {
+	mu      sync.Mutex
+	err     error
+	renames []string
+}
+
+func (f *fakeReservations) RenameActivity(ctx context.Context, name string, newname string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.renames = append(f.renames, name+"->"+newname)
+	return 1, nil
+}
+
+// addActivity saves the activity for the test.
+func addActivity(t *testing.T, name string) {
+	t.Helper()
+
+	err := models.CreateActivity(&models.Activity{Name: name})
+	if err != nil {
+		t.Fatalf("CreateActivity: %v", err)
+	}
+}
+
+// pendingRenames returns the renames the reservation server has not made yet.
+func pendingRenames(t *testing.T) []models.PendingRename {
+	t.Helper()
+
+	renames, err := models.GetPendingRenames()
+	if err != nil {
+		t.Fatalf("GetPendingRenames: %v", err)
+	}
+	return renames
+}
+
+// serve runs the handler on the request and returns its response.
+func serve(t *testing.T, handler func(conn *net.Conn, req *helper.Request), request string) string {
+	t.Helper()
+
+	req, err := helper.ReadRequest(bufio.NewReader(strings.NewReader(request)))
+	if err != nil {
+		t.Fatalf("cannot make request: %v", err)
+	}
+	server, conn := net.Pipe()
+	go handler(&server, req)
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("cannot read response: %v", err)
+	}
+	return string(response)
+}
+
+func TestHandleUpdateRenamesReservations(t *testing.T) {
+	useTestDatabase(t)
+	reservations := &fakeReservations{}
+	reservationClient = reservations
+	addActivity(t, "Lecture")
+
+	response := serve(t, HandleUpdate, "GET /update?name=Lecture&newname=Talk HTTP/1.1\r\n\r\n")
+	if !strings.HasPrefix(response, "HTTP/1.0 200") {
+		t.Fatalf("got response %s, want 200", response)
+	}
+	if fmt.Sprint(reservations.renames) != "[Lecture->Talk]" {
+		t.Errorf("reservation server made renames %v, want [Lecture->Talk]", reservations.renames)
+	}
+	if pending := pendingRenames(t); len(pending) != 0 {
+		t.Errorf("got pending renames %+v, want none", pending)
+	}
+}
+
+func TestHandleUpdateKeepsRenameWhileReservationServerIsDown(t *testing.T) {
+	useTestDatabase(t)
+	reservations := &fakeReservations{err: context.DeadlineExceeded}
+	reservationClient = reservations
+	addActivity(t, "Lecture")
+
+	response := serve(t, HandleUpdate, "GET /update?name=Lecture&newname=Talk HTTP/1.1\r\n\r\n")
+	if !strings.HasPrefix(response, "HTTP/1.0 200") {
+		t.Fatalf("got response %s, want 200", response)
+	}
+	exists, err := models.CheckActivity("Talk")
+	if err != nil || !exists {
+		t.Errorf("activity is not named Talk, want the rename kept: %v", err)
+	}
+	if pending := pendingRenames(t); len(pending) != 1 {
+		t.Fatalf("got pending renames %+v, want the one the reservation server missed", pending)
+	}
+
+	// the rename is sent again once the reservation server is back
+	reservations.err = nil
+	err = sendPendingRenames(reservations)
+	if err != nil {
+		t.Fatalf("sendPendingRenames: %v", err)
+	}
+	if fmt.Sprint(reservations.renames) != "[Lecture->Talk]" {
+		t.Errorf("reservation server made renames %v, want [Lecture->Talk]", reservations.renames)
+	}
+	if pending := pendingRenames(t); len(pending) != 0 {
+		t.Errorf("got pending renames %+v, want none", pending)
+	}
+}
+
+func TestSendPendingRenamesInOrder(t *testing.T) {
+	useTestDatabase(t)
+	reservations := &fakeReservations{err: context.DeadlineExceeded}
+	addActivity(t, "Lecture")
+
+	for _, rename := range [][2]string{{"Lecture", "Talk"}, {"Talk", "Seminar"}} {
+		err := models.RenameActivity(rename[0], rename[1])
+		if err != nil {
+			t.Fatalf("RenameActivity: %v", err)
+		}
+		err = sendPendingRenames(reservations)
+		if err == nil {
+			t.Fatal("sendPendingRenames succeeded while the reservation server is down")
+		}
+	}
+
+	reservations.err = nil
+	err := sendPendingRenames(reservations)
+	if err != nil {
+		t.Fatalf("sendPendingRenames: %v", err)
+	}
+	if fmt.Sprint(reservations.renames) != "[Lecture->Talk Talk->Seminar]" {
+		t.Errorf("reservation server made renames %v, want [Lecture->Talk Talk->Seminar]", reservations.renames)
+	}
+}
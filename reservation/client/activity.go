@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Activity is an activity known by the activity server.
+type Activity struct {
+	Name string `json:"activity_name"`
+}
+
+// ActivityClient calls the activity server.
+type ActivityClient struct {
+	address string
+}
+
+// NewActivityClient returns a client for the activity server listening on address, e.g. "localhost:8081".
+func NewActivityClient(address string) *ActivityClient {
+	return &ActivityClient{address: address}
+}
+
+func (c *ActivityClient) Health(ctx context.Context) error {
+	return do(ctx, c.address, "GET", apiPrefix+"/health", nil, nil)
+}
+
+// AddActivity creates an activity, it fails with ErrConflict if the name is taken.
+func (c *ActivityClient) AddActivity(ctx context.Context, name string) (*Activity, error) {
+	activity := &Activity{}
+	err := do(ctx, c.address, "POST", "/add", Activity{Name: name}, activity)
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// RemoveActivity deletes an activity, it fails with ErrNotFound if there is no such activity.
+func (c *ActivityClient) RemoveActivity(ctx context.Context, name string) error {
+	return do(ctx, c.address, "POST", "/remove", Activity{Name: name}, nil)
+}
+
+// CheckActivity returns the activity with the given name, it fails with ErrNotFound if there is none.
+func (c *ActivityClient) CheckActivity(ctx context.Context, name string) (*Activity, error) {
+	activity := &Activity{}
+	err := do(ctx, c.address, "GET", fmt.Sprintf("%s/activity?name=%s", apiPrefix, url.QueryEscape(name)), nil, activity)
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
@@ -0,0 +1,228 @@
+// Package client talks to the room, activity and reservation servers in JSON. Every call takes
+// a context, its deadline bounds the whole round trip and cancelling it aborts the call.
+//
+// Servers' errors are returned as *Error, which can be matched with errors.Is against
+// ErrBadRequest, ErrNotFound, ErrConflict and ErrServer.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+)
+
+// apiPrefix is the version of the internal API this package speaks.
+const apiPrefix = "/internal/v1"
+
+// Error codes the servers put into their JSON answers.
+const (
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidBody          = "invalid_body"
+	CodeValidation           = "validation_failed"
+	CodeDatabase             = "database_error"
+	CodeRoomExists           = "room_exists"
+	CodeRoomNotFound         = "room_not_found"
+	CodeRoomReserved         = "room_reserved"
+	CodeRoomBlackedOut       = "room_blacked_out"
+	CodeBuildingNotFound     = "building_not_found"
+	CodeActivityExists       = "activity_exists"
+	CodeActivityNotFound     = "activity_not_found"
+	CodeReservationNotFound  = "reservation_not_found"
+	CodeUpstream             = "upstream_error"
+	CodeRequestInProgress    = "request_in_progress"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+)
+
+// DefaultTimeout bounds a call whose context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
+// Kinds of errors, an *Error matches one of them according to its status code.
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrServer     = errors.New("server error")
+)
+
+// Error is a server's answer with a status other than 200 OK. Data holds the details some
+// errors come with, such as the conflicts of a batch.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Data    json.RawMessage
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Is maps the status code to the error kinds. The room server answers 403 Forbidden for
+// some missing rooms, so the *_not_found codes count as ErrNotFound too.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.Status == 400 || e.Status == 405 || e.Status == 422
+	case ErrNotFound:
+		return e.Status == 404 || strings.HasSuffix(e.Code, "_not_found")
+	case ErrConflict:
+		return (e.Status == 403 || e.Status == 409) && !strings.HasSuffix(e.Code, "_not_found")
+	case ErrServer:
+		return e.Status >= 500
+	}
+	return false
+}
+
+// envelope is the document every internal API answer comes in.
+type envelope struct {
+	Status  int             `json:"status"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// do sends a single request to the server at address and decodes the data of a
+// successful answer into data. A non 200 answer is returned as an *Error.
+func do(ctx context.Context, address string, method string, path string, body interface{}, data interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	// a cancelled context unblocks the reads and writes below
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	err = roundTrip(conn, method, path, body, data)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func roundTrip(conn net.Conn, method string, path string, body interface{}, data interface{}) error {
+	var err error
+
+	payload := []byte{}
+	if body != nil {
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	request := strings.Builder{}
+	request.WriteString(method + " " + path + " HTTP/1.0\r\n")
+	request.WriteString("Accept: application/json\r\n")
+	if body != nil {
+		request.WriteString("Content-Type: application/json\r\n")
+		request.WriteString("Content-Length: " + strconv.Itoa(len(payload)) + "\r\n")
+	}
+	request.WriteString("\r\n")
+	request.Write(payload)
+
+	_, err = io.WriteString(conn, request.String())
+	if err != nil {
+		return err
+	}
+
+	status, answer, err := readResponse(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	if answer.Status == 0 {
+		answer.Status = status
+	}
+	if answer.Status != 200 {
+		return &Error{Status: answer.Status, Code: answer.Error, Message: answer.Message, Data: answer.Data}
+	}
+	if data != nil && len(answer.Data) > 0 {
+		return json.Unmarshal(answer.Data, data)
+	}
+	return nil
+}
+
+// readResponse reads the status line, the headers and the JSON body of an answer.
+func readResponse(r *bufio.Reader) (int, *envelope, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return 0, nil, err
+	}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "HTTP/1.") {
+		return 0, nil, fmt.Errorf("malformed status line %q", line)
+	}
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed status code %q", parts[1])
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+
+	var body []byte
+	if cl := header.Get("Content-Length"); cl != "" {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 {
+			return 0, nil, fmt.Errorf("malformed Content-Length %q", cl)
+		}
+		// a bogus Content-Length cannot make the client allocate unbounded memory
+		if length > helper.MaxBodySize {
+			return 0, nil, errors.New("answer body too large")
+		}
+		body = make([]byte, length)
+		_, err = io.ReadFull(r, body)
+		if err != nil {
+			return 0, nil, err
+		}
+	} else {
+		// the servers close the connection after the answer
+		body, err = io.ReadAll(io.LimitReader(r, helper.MaxBodySize+1))
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(body) > helper.MaxBodySize {
+			return 0, nil, errors.New("answer body too large")
+		}
+	}
+
+	if !strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+		return status, nil, &Error{Status: status, Message: fmt.Sprintf("unexpected %q answer", header.Get("Content-Type"))}
+	}
+	answer := &envelope{}
+	err = json.Unmarshal(body, answer)
+	if err != nil {
+		return 0, nil, err
+	}
+	return status, answer, nil
+}
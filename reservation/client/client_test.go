@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+)
+
+// stub serves a single connection with the raw answer and sends the request it read on the
+// returned channel.
+func stub(t *testing.T, answer string) (string, <-chan *helper.Request) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	requests := make(chan *helper.Request, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := helper.ReadRequest(bufio.NewReader(conn))
+		if err == nil {
+			requests <- req
+		}
+		conn.Write([]byte(answer))
+	}()
+	return ln.Addr().String(), requests
+}
+
+// jsonAnswer is a JSON answer with the status line's status and the document.
+func jsonAnswer(status string, doc string) string {
+	return fmt.Sprintf("HTTP/1.0 %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", status, len(doc), doc)
+}
+
+func TestErrorIs(t *testing.T) {
+	kinds := []error{ErrBadRequest, ErrNotFound, ErrConflict, ErrServer}
+	tests := []struct {
+		status int
+		code   string
+		want   error
+	}{
+		{400, CodeInvalidParameter, ErrBadRequest},
+		{405, "", ErrBadRequest},
+		{422, CodeIdempotencyKeyReused, ErrBadRequest},
+		{404, CodeReservationNotFound, ErrNotFound},
+		{404, "", ErrNotFound},
+		{403, CodeRoomNotFound, ErrNotFound},
+		{403, CodeBuildingNotFound, ErrNotFound},
+		{403, CodeRoomReserved, ErrConflict},
+		{403, CodeActivityExists, ErrConflict},
+		{409, CodeRequestInProgress, ErrConflict},
+		{500, CodeDatabase, ErrServer},
+		{502, CodeUpstream, ErrServer},
+		{504, CodeUpstream, ErrServer},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.status, tt.code), func(t *testing.T) {
+			err := error(&Error{Status: tt.status, Code: tt.code})
+			for _, kind := range kinds {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", err, kind, got, kind == tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		want     string
+		wantKind error
+		wantErr  string
+	}{
+		{"success", jsonAnswer("200 OK", `{"status":200,"data":{"activity_name":"Lecture"}}`), "Lecture", nil, ""},
+		{"success without content length",
+			"HTTP/1.0 200 OK\r\nContent-Type: application/json\r\n\r\n" + `{"status":200,"data":{"activity_name":"Lecture"}}`,
+			"Lecture", nil, ""},
+		{"not found in a forbidden answer",
+			jsonAnswer("403 Forbidden", `{"status":403,"error":"room_not_found","message":"Room does not exists"}`),
+			"", ErrNotFound, "403 room_not_found: Room does not exists"},
+		{"unprocessable", jsonAnswer("422 Unprocessable Entity", `{"status":422,"error":"idempotency_key_reused"}`),
+			"", ErrBadRequest, "422 idempotency_key_reused: "},
+		{"status of the status line when the document has none", jsonAnswer("404 Not Found", `{"error":"activity_not_found"}`),
+			"", ErrNotFound, "404 activity_not_found: "},
+		{"non JSON answer", "HTTP/1.0 500 Internal Server Error\r\nContent-Type: text/html\r\nContent-Length: 4\r\n\r\noops",
+			"", ErrServer, `500 : unexpected "text/html" answer`},
+		{"malformed JSON", jsonAnswer("200 OK", `{"status":`), "", nil, "unexpected end of JSON input"},
+		{"malformed status line", "HTTP/1.0\r\n\r\n", "", nil, `malformed status line "HTTP/1.0"`},
+		{"malformed status code", "HTTP/1.0 OK\r\n\r\n", "", nil, `malformed status code "OK"`},
+		{"malformed content length", "HTTP/1.0 200 OK\r\nContent-Length: -1\r\n\r\n", "", nil, `malformed Content-Length "-1"`},
+		{"content length over the limit",
+			fmt.Sprintf("HTTP/1.0 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", helper.MaxBodySize+1),
+			"", nil, "answer body too large"},
+		{"body over the limit without content length",
+			"HTTP/1.0 200 OK\r\nContent-Type: application/json\r\n\r\n" + strings.Repeat(" ", helper.MaxBodySize+1),
+			"", nil, "answer body too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, requests := stub(t, tt.answer)
+
+			activity := &Activity{}
+			err := do(context.Background(), address, "POST", "/add", Activity{Name: "Lecture"}, activity)
+
+			req := <-requests
+			if req.Method != "POST" || req.Path != "/add" || string(req.Body) != `{"activity_name":"Lecture"}` {
+				t.Errorf("server got %s %s %s, want the request as it was made", req.Method, req.Path, req.Body)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("do() error = %v, want none", err)
+				}
+				if activity.Name != tt.want {
+					t.Errorf("got activity %q, want %q", activity.Name, tt.want)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("do() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Errorf("do() error = %v, want it to match %v", err, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestDoTimesOut(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	// the server accepts the connection but never answers
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			bufio.NewReader(conn).ReadString(0)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = do(ctx, ln.Addr().String(), "GET", "/health", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Clock is a time of day in minutes after midnight, it is written as "15:04" in JSON.
+type Clock int
+
+// EndOfDay is the midnight at the end of a day, it is written as "24:00".
+const EndOfDay = Clock(24 * 60)
+
+// ClockOf returns the clock at the given hour and minute.
+func ClockOf(hour int, minute int) Clock {
+	return Clock(hour*60 + minute)
+}
+
+// ParseClock parses a time of day such as 09:30, or 24:00 for the end of the day.
+func ParseClock(clock string) (Clock, error) {
+	if clock == "24:00" {
+		return EndOfDay, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.New("time should be in HH:MM format")
+	}
+	return ClockOf(t.Hour(), t.Minute()), nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c Clock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *Clock) UnmarshalJSON(data []byte) error {
+	var clock string
+	err := json.Unmarshal(data, &clock)
+	if err != nil {
+		return errors.New("time should be a string in HH:MM format")
+	}
+	*c, err = ParseClock(clock)
+	return err
+}
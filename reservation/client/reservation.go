@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// RoomReservation is a reservation of a room for an activity, kept by the reservation server.
+// With a Date it is made for that date only, without one it recurs every week on its Day. Its
+// time is from Start to End, it can be given in whole hours with Hour and Duration instead.
+type RoomReservation struct {
+	ID           uint   `json:"id"`
+	ActivityName string `json:"activity_name"`
+	RoomName     string `json:"room_name"`
+	Date         string `json:"date,omitempty"`
+	Day          int    `json:"day"`
+	Start        Clock  `json:"start"`
+	End          Clock  `json:"end"`
+	Hour         int    `json:"hour,omitempty"`
+	Duration     int    `json:"duration,omitempty"`
+	SeriesID     uint   `json:"series_id,omitempty"`
+	// IdempotencyKey makes the retries of Reserve with the same key return the first reservation
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// Series is a reservation that repeats by an RFC 5545 recurrence rule from its Date on, such
+// as FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=10. The rule supports the FREQ (DAILY, WEEKLY or
+// MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL parts.
+type Series struct {
+	ID           uint              `json:"id"`
+	ActivityName string            `json:"activity_name"`
+	RoomName     string            `json:"room_name"`
+	Date         string            `json:"date"`
+	Start        Clock             `json:"start"`
+	End          Clock             `json:"end"`
+	Hour         int               `json:"hour,omitempty"`
+	Duration     int               `json:"duration,omitempty"`
+	RRule        string            `json:"rrule"`
+	Occurrences  []RoomReservation `json:"occurrences,omitempty"`
+}
+
+// ReservationClient calls the reservation server.
+type ReservationClient struct {
+	address string
+}
+
+// NewReservationClient returns a client for the reservation server listening on address.
+func NewReservationClient(address string) *ReservationClient {
+	return &ReservationClient{address: address}
+}
+
+// Reserve books a room for an activity, it fails with ErrNotFound if either of them does not
+// exist. When the room is reserved or blacked out, it returns what can be reserved instead
+// along with an error matching ErrConflict.
+func (c *ReservationClient) Reserve(ctx context.Context, reservation RoomReservation) (*RoomReservation, *Alternatives, error) {
+	created := &RoomReservation{}
+	err := do(ctx, c.address, "POST", "/reserve", reservation, created)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && (apiErr.Code == CodeRoomReserved || apiErr.Code == CodeRoomBlackedOut) &&
+			len(apiErr.Data) > 0 {
+			alternatives := &Alternatives{}
+			if json.Unmarshal(apiErr.Data, alternatives) == nil {
+				return nil, alternatives, err
+			}
+		}
+		return nil, nil, err
+	}
+	return created, nil, nil
+}
+
+// Cancel cancels the reservation with the given id and frees its time on the room server,
+// it fails with ErrNotFound if there is no such reservation.
+func (c *ReservationClient) Cancel(ctx context.Context, id uint) (*RoomReservation, error) {
+	cancelled := &RoomReservation{}
+	err := do(ctx, c.address, "POST", "/cancel", struct {
+		ID uint `json:"id"`
+	}{ID: id}, cancelled)
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// Reschedule moves the reservation with the given ID to the room, date or day and time of
+// the given one, the ones left empty are kept. The reservation keeps its ID and stays as it
+// was if the new time cannot be booked.
+func (c *ReservationClient) Reschedule(ctx context.Context, reservation RoomReservation) (*RoomReservation, error) {
+	rescheduled := &RoomReservation{}
+	err := do(ctx, c.address, "POST", "/reschedule", reservation, rescheduled)
+	if err != nil {
+		return nil, err
+	}
+	return rescheduled, nil
+}
+
+// RenameActivity moves the reservations of the activity to its new name and returns how many
+// of them were renamed. Renaming is idempotent, so a rename whose answer was lost can be made
+// again.
+func (c *ReservationClient) RenameActivity(ctx context.Context, name string, newname string) (int64, error) {
+	renamed := struct {
+		Reservations int64 `json:"reservations"`
+	}{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/activities/rename", struct {
+		Name    string `json:"activity_name"`
+		NewName string `json:"new_name"`
+	}{Name: name, NewName: newname}, &renamed)
+	if err != nil {
+		return 0, err
+	}
+	return renamed.Reservations, nil
+}
+
+// ReserveSeries books every occurrence of the series or none of them. When some of them
+// conflict, it returns them along with an error matching ErrConflict.
+func (c *ReservationClient) ReserveSeries(ctx context.Context, series Series) (*Series, []Conflict, error) {
+	created := &Series{}
+	err := do(ctx, c.address, "POST", "/reserveseries", series, created)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Code == CodeRoomReserved && len(apiErr.Data) > 0 {
+			conflicts := []Conflict{}
+			if json.Unmarshal(apiErr.Data, &conflicts) == nil {
+				return nil, conflicts, err
+			}
+		}
+		return nil, nil, err
+	}
+	return created, nil, nil
+}
+
+// ReserveGroup books the rooms of the reservations for the activity, each at its own time, all
+// of them or none of them. When some of them conflict, it returns them along with an error
+// matching ErrConflict.
+func (c *ReservationClient) ReserveGroup(ctx context.Context, activityname string, reservations []RoomReservation) ([]RoomReservation, []Conflict, error) {
+	created := []RoomReservation{}
+	err := do(ctx, c.address, "POST", "/reservegroup", struct {
+		ActivityName string            `json:"activity_name"`
+		Reservations []RoomReservation `json:"reservations"`
+	}{ActivityName: activityname, Reservations: reservations}, &created)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Code == CodeRoomReserved && len(apiErr.Data) > 0 {
+			conflicts := []Conflict{}
+			if json.Unmarshal(apiErr.Data, &conflicts) == nil {
+				return nil, conflicts, err
+			}
+		}
+		return nil, nil, err
+	}
+	return created, nil, nil
+}
+
+// CancelSeries cancels every occurrence of the series and returns the cancelled ones.
+func (c *ReservationClient) CancelSeries(ctx context.Context, id uint) (*Series, error) {
+	return c.cancelSeries(ctx, id, "")
+}
+
+// CancelOccurrence cancels the occurrence of the series on the given date.
+func (c *ReservationClient) CancelOccurrence(ctx context.Context, id uint, date string) (*Series, error) {
+	return c.cancelSeries(ctx, id, date)
+}
+
+func (c *ReservationClient) cancelSeries(ctx context.Context, id uint, date string) (*Series, error) {
+	cancelled := &Series{}
+	err := do(ctx, c.address, "POST", "/cancelseries", struct {
+		ID   uint   `json:"id"`
+		Date string `json:"date,omitempty"`
+	}{ID: id, Date: date}, cancelled)
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+func (c *ReservationClient) CheckAvailability(ctx context.Context, room string, day int) (*DayAvailability, error) {
+	availability := &DayAvailability{}
+	err := do(ctx, c.address, "POST", "/listavailibility", availabilityQuery{RoomName: room, Day: day}, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// availabilityQuery is the body of the reservation server's /listavailibility endpoint.
+type availabilityQuery struct {
+	RoomName string `json:"room_name"`
+	Day      int    `json:"day,omitempty"`
+	Date     string `json:"date,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+func (c *ReservationClient) CheckDateAvailability(ctx context.Context, room string, date string) (*DayAvailability, error) {
+	availability := &DayAvailability{}
+	err := do(ctx, c.address, "POST", "/listavailibility", availabilityQuery{RoomName: room, Date: date}, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+func (c *ReservationClient) RangeAvailability(ctx context.Context, room string, from string, to string) (*RangeAvailability, error) {
+	availability := &RangeAvailability{}
+	err := do(ctx, c.address, "POST", "/listavailibility", availabilityQuery{RoomName: room, From: from, To: to},
+		availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+func (c *ReservationClient) WeeklyAvailability(ctx context.Context, room string) (*WeeklyAvailability, error) {
+	availability := &WeeklyAvailability{}
+	err := do(ctx, c.address, "POST", "/listavailibility", availabilityQuery{RoomName: room}, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// Display returns the reservation with the given id, it fails with ErrNotFound if there is none.
+func (c *ReservationClient) Display(ctx context.Context, id uint) (*RoomReservation, error) {
+	reservation := &RoomReservation{}
+	err := do(ctx, c.address, "POST", "/display", struct {
+		ID uint `json:"id"`
+	}{ID: id}, reservation)
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ReservationFilter selects the reservations to list, the empty fields match every reservation.
+// Date selects a single date, From and To a range of them, and the reservations that recur
+// every week match the dates their day falls on. Hour matches the reservations taking place in
+// that hour. Sort is one of date, start, room, activity or id, descending with a leading "-".
+// Cursor is the NextCursor of the previous page.
+type ReservationFilter struct {
+	RoomName     string `json:"room_name,omitempty"`
+	ActivityName string `json:"activity_name,omitempty"`
+	Day          int    `json:"day,omitempty"`
+	Date         string `json:"date,omitempty"`
+	From         string `json:"from,omitempty"`
+	To           string `json:"to,omitempty"`
+	Hour         *int   `json:"hour,omitempty"`
+	Sort         string `json:"sort,omitempty"`
+	Cursor       string `json:"cursor,omitempty"`
+	Limit        int    `json:"limit,omitempty"`
+}
+
+// ReservationPage is a page of a listing, NextCursor is empty on the last page.
+type ReservationPage struct {
+	Reservations []RoomReservation `json:"reservations"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+}
+
+// Reservations returns a page of the reservations the filter selects.
+func (c *ReservationClient) Reservations(ctx context.Context, filter ReservationFilter) (*ReservationPage, error) {
+	page := &ReservationPage{}
+	err := do(ctx, c.address, "POST", "/reservations", filter, page)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
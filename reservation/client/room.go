@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Room is a room known by the room server. Capacity is how many people fit in it, zero when
+// it is not known, and Equipment lists what it has, such as projector or whiteboard.
+type Room struct {
+	Name         string     `json:"room_name"`
+	Capacity     int        `json:"capacity"`
+	Building     string     `json:"building"`
+	Floor        int        `json:"floor"`
+	Equipment    []string   `json:"equipment"`
+	OpeningHours []DayHours `json:"opening_hours"`
+}
+
+// DayHours is the time a room is open on a day of the week, a room without any is open
+// 09:00 to 17:00 every day.
+type DayHours struct {
+	Day   int   `json:"day"`
+	Start Clock `json:"start"`
+	End   Clock `json:"end"`
+}
+
+// Reservation is a booked time slice of a room on the room server.
+// A reservation with a Date is made for that date only, without one it recurs every week on its Day.
+// Its time is from Start to End, it can be given in whole hours with Hour and Duration instead.
+type Reservation struct {
+	ID       uint   `json:"id"`
+	RoomName string `json:"room_name"`
+	Date     string `json:"date,omitempty"`
+	Day      int    `json:"day"`
+	Start    Clock  `json:"start"`
+	End      Clock  `json:"end"`
+	Hour     int    `json:"hour,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	// Reference lets the reservation be released without knowing its ID
+	Reference string `json:"reference,omitempty"`
+}
+
+// Conflict is a reservation of a batch that cannot be made, along with the reason.
+type Conflict struct {
+	Index       int         `json:"index"`
+	Reservation Reservation `json:"reservation"`
+	Reason      string      `json:"reason"`
+}
+
+// Alternatives are what can be reserved instead of a reservation that conflicts: the nearest
+// free Slots of the same length in the same room, and the OtherRooms free at the same time.
+type Alternatives struct {
+	Slots      []Reservation `json:"slots"`
+	OtherRooms []Room        `json:"other_rooms"`
+}
+
+// Interval is the half-open time range [Start, End) of a day.
+type Interval struct {
+	Start Clock `json:"start"`
+	End   Clock `json:"end"`
+}
+
+// DayAvailability lists the free time ranges of a room on a date or on a day of the week,
+// and the times a reservation of a single slot can start at.
+type DayAvailability struct {
+	RoomName string     `json:"room_name"`
+	Date     string     `json:"date,omitempty"`
+	Day      int        `json:"day"`
+	Open     *Interval  `json:"open,omitempty"`
+	Closed   bool       `json:"closed,omitempty"`
+	Free     []Interval `json:"free,omitempty"`
+	Slots    []Clock    `json:"slots"`
+}
+
+// WeeklyAvailability lists the free hours of a room for every day of the week.
+type WeeklyAvailability struct {
+	RoomName string            `json:"room_name"`
+	Days     []DayAvailability `json:"days"`
+}
+
+// RangeAvailability lists the free hours of a room for every date of a date range.
+type RangeAvailability struct {
+	RoomName string            `json:"room_name"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Days     []DayAvailability `json:"days"`
+}
+
+// RoomSearch is a time window and what a room should have to be found for it. The window is
+// on a Date, or on a Day of the week every week, from Start to End, which may be given in
+// whole hours with Hour and Duration instead. Capacity is the least number of people the room
+// should fit, and Equipment what it should have. The search is limited to the rooms of a
+// Building, and to one of its floors when a Floor is given as well.
+type RoomSearch struct {
+	Date      string   `json:"date,omitempty"`
+	Day       int      `json:"day"`
+	Start     Clock    `json:"start"`
+	End       Clock    `json:"end"`
+	Hour      int      `json:"hour,omitempty"`
+	Duration  int      `json:"duration,omitempty"`
+	Capacity  int      `json:"capacity,omitempty"`
+	Equipment []string `json:"equipment,omitempty"`
+	Building  string   `json:"building,omitempty"`
+	Floor     *int     `json:"floor,omitempty"`
+}
+
+// RoomSearchResult is the window that was searched and the rooms found for it, the best
+// fitting first.
+type RoomSearchResult struct {
+	Date  string `json:"date,omitempty"`
+	Day   int    `json:"day"`
+	Start Clock  `json:"start"`
+	End   Clock  `json:"end"`
+	Rooms []Room `json:"rooms"`
+}
+
+// RoomClient calls the room server.
+type RoomClient struct {
+	address string
+}
+
+// NewRoomClient returns a client for the room server listening on address, e.g. "localhost:8080".
+func NewRoomClient(address string) *RoomClient {
+	return &RoomClient{address: address}
+}
+
+func (c *RoomClient) Health(ctx context.Context) error {
+	return do(ctx, c.address, "GET", apiPrefix+"/health", nil, nil)
+}
+
+// AddRoom creates a room, it fails with ErrConflict if the name is taken.
+func (c *RoomClient) AddRoom(ctx context.Context, name string) (*Room, error) {
+	room := &Room{}
+	err := do(ctx, c.address, "POST", "/add", Room{Name: name}, room)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// RemoveRoom deletes a room, it fails with ErrNotFound if there is no such room.
+func (c *RoomClient) RemoveRoom(ctx context.Context, name string) error {
+	return do(ctx, c.address, "POST", "/remove", Room{Name: name}, nil)
+}
+
+// Reserve books the time slice of the reservation. When it is taken or the room is blacked out,
+// it returns what can be reserved instead along with an error matching ErrConflict.
+func (c *RoomClient) Reserve(ctx context.Context, reservation Reservation) (*Reservation, *Alternatives, error) {
+	created := &Reservation{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/reservations", reservation, created)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && (apiErr.Code == CodeRoomReserved || apiErr.Code == CodeRoomBlackedOut) &&
+			len(apiErr.Data) > 0 {
+			alternatives := &Alternatives{}
+			if json.Unmarshal(apiErr.Data, alternatives) == nil {
+				return nil, alternatives, err
+			}
+		}
+		return nil, nil, err
+	}
+	return created, nil, nil
+}
+
+// ReserveBatch books either all of the reservations or none of them. When some of them
+// conflict, it returns them along with an error matching ErrConflict.
+func (c *RoomClient) ReserveBatch(ctx context.Context, reservations []Reservation) ([]Reservation, []Conflict, error) {
+	created := []Reservation{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/reservations/batch", reservations, &created)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Code == CodeRoomReserved && len(apiErr.Data) > 0 {
+			conflicts := []Conflict{}
+			if json.Unmarshal(apiErr.Data, &conflicts) == nil {
+				return nil, conflicts, err
+			}
+		}
+		return nil, nil, err
+	}
+	return created, nil, nil
+}
+
+// Reservations returns every reservation the room server has, the past ones included.
+func (c *RoomClient) Reservations(ctx context.Context) ([]Reservation, error) {
+	reservations := []Reservation{}
+	err := do(ctx, c.address, "GET", apiPrefix+"/reservations", nil, &reservations)
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// Release removes the reservations with the given ids and returns the ids of the removed
+// ones, the ids the room server does not know are skipped.
+func (c *RoomClient) Release(ctx context.Context, ids []uint) ([]uint, error) {
+	return c.release(ctx, release{IDs: ids})
+}
+
+// ReleaseReferences removes the reservations with the given references and returns their ids,
+// it also works when the answer holding the ids of the reservations was never received.
+func (c *RoomClient) ReleaseReferences(ctx context.Context, references ...string) ([]uint, error) {
+	return c.release(ctx, release{IDs: []uint{}, References: references})
+}
+
+// ReleaseMatching removes a reservation with the same room, date, day, start and end as each
+// of the given ones, for the reservations whose ids are not known. It returns the ids of the
+// removed ones.
+func (c *RoomClient) ReleaseMatching(ctx context.Context, reservations []Reservation) ([]uint, error) {
+	return c.release(ctx, release{IDs: []uint{}, Reservations: reservations})
+}
+
+// release is the body of the room server's release endpoint.
+type release struct {
+	IDs          []uint        `json:"ids"`
+	References   []string      `json:"references,omitempty"`
+	Reservations []Reservation `json:"reservations,omitempty"`
+}
+
+func (c *RoomClient) release(ctx context.Context, body release) ([]uint, error) {
+	released := struct {
+		IDs []uint `json:"ids"`
+	}{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/reservations/release", body, &released)
+	if err != nil {
+		return nil, err
+	}
+	return released.IDs, nil
+}
+
+// Swap replaces the old reservation with the replacement at once, the old one is only removed
+// if the replacement can be made. The old reservation is found by its ID, or when that is zero
+// by its room, date, day, start and end. It fails with ErrConflict if the new time is taken
+// and with ErrNotFound if there is no such old reservation.
+func (c *RoomClient) Swap(ctx context.Context, old Reservation, replacement Reservation) (*Reservation, error) {
+	swapped := &Reservation{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/reservations/swap", struct {
+		Old         Reservation `json:"old"`
+		Replacement Reservation `json:"replacement"`
+	}{Old: old, Replacement: replacement}, swapped)
+	if err != nil {
+		return nil, err
+	}
+	return swapped, nil
+}
+
+// CancelReservation removes the reservation with the given id, it fails with ErrNotFound if
+// there is none.
+func (c *RoomClient) CancelReservation(ctx context.Context, id uint) (*Reservation, error) {
+	cancelled := &Reservation{}
+	err := do(ctx, c.address, "POST", "/cancel", struct {
+		ID uint `json:"id"`
+	}{ID: id}, cancelled)
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+func (c *RoomClient) CheckAvailability(ctx context.Context, room string, day int) (*DayAvailability, error) {
+	availability := &DayAvailability{}
+	err := do(ctx, c.address, "GET", fmt.Sprintf("%s/availability?room=%s&day=%d", apiPrefix, url.QueryEscape(room), day),
+		nil, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// CheckDateAvailability returns the free time of the room on the date, e.g. "2022-11-25".
+func (c *RoomClient) CheckDateAvailability(ctx context.Context, room string, date string) (*DayAvailability, error) {
+	availability := &DayAvailability{}
+	err := do(ctx, c.address, "GET", fmt.Sprintf("%s/availability?room=%s&date=%s", apiPrefix, url.QueryEscape(room),
+		url.QueryEscape(date)), nil, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// RangeAvailability returns the free time of the room for every date from the first to the last one.
+func (c *RoomClient) RangeAvailability(ctx context.Context, room string, from string, to string) (*RangeAvailability, error) {
+	availability := &RangeAvailability{}
+	err := do(ctx, c.address, "GET", fmt.Sprintf("%s/availability?room=%s&from=%s&to=%s", apiPrefix,
+		url.QueryEscape(room), url.QueryEscape(from), url.QueryEscape(to)), nil, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+func (c *RoomClient) WeeklyAvailability(ctx context.Context, room string) (*WeeklyAvailability, error) {
+	availability := &WeeklyAvailability{}
+	err := do(ctx, c.address, "GET", fmt.Sprintf("%s/weeklyavailability?room=%s", apiPrefix, url.QueryEscape(room)),
+		nil, availability)
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// SearchRooms returns the rooms that have what the search asks for and are free for its whole
+// window, the best fitting first. It fails with ErrBadRequest if the window is not valid.
+func (c *RoomClient) SearchRooms(ctx context.Context, search RoomSearch) (*RoomSearchResult, error) {
+	result := &RoomSearchResult{}
+	err := do(ctx, c.address, "POST", apiPrefix+"/rooms/search", search, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -1,8 +1,6 @@
 package database
 
 import (
-	"log"
-
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -11,10 +9,10 @@ var (
 	DBConn *gorm.DB
 )
 
-func init() {
+// Connect opens the database at path, the models use it from then on. The server connects
+// to its own database at start-up, the tests to one of their own.
+func Connect(path string) error {
 	var err error
-	DBConn, err = gorm.Open(sqlite.Open("reservation.db"), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Cannot connect to database:", err)
-	}
+	DBConn, err = gorm.Open(sqlite.Open(path), &gorm.Config{})
+	return err
 }
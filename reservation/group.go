@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// MaxGroupSize bounds how many rooms a group reservation can book at once.
+const MaxGroupSize = 20
+
+// HandleReserveGroup books several rooms for one activity at once, each at its own time, or
+// none of them and lists the conflicting ones. A GET request books the comma separated rooms
+// all at the same time.
+func HandleReserveGroup(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		ActivityName string                   `json:"activity_name"`
+		Reservations []models.RoomReservation `json:"reservations"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		var slot models.RoomReservation
+		var err error
+		body.ActivityName = req.Query.Get("activity")
+		// a date books that date only, a day of the week books it every week
+		slot.Date = req.Query.Get("date")
+		if slot.Date == "" {
+			slot.Day, err = req.IntParam("day")
+		}
+		if err == nil {
+			err = readTimeParams(req, &slot)
+		}
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser error.", err.Error())
+
+			return
+		}
+		for _, room := range strings.Split(req.Query.Get("rooms"), ",") {
+			if strings.TrimSpace(room) != "" {
+				slot.RoomName = strings.TrimSpace(room)
+				body.Reservations = append(body.Reservations, slot)
+			}
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser error.", err.Error())
+
+			return
+		}
+	}
+
+	if len(body.Reservations) == 0 {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", "group has no rooms to reserve")
+
+		return
+	}
+	if len(body.Reservations) > MaxGroupSize {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", fmt.Sprintf("group cannot have more than %d rooms", MaxGroupSize))
+
+		return
+	}
+
+	response = bookings.reserveGroup(req, body.ActivityName, body.Reservations)
+}
+
+// reserveGroup books the rooms of the reservations for the activity as a single saga, like
+// reserve does for one room. The room server books all of them or none of them, so when one
+// of them is not available there is nothing to release. When the call fails or times out, or
+// the reservations cannot be confirmed, every booking made is released by its reference.
+func (b *booker) reserveGroup(req *helper.Request, activityname string, slots []models.RoomReservation) string {
+	// keep a reconciliation from taking the bookings of a running saga for orphaned ones
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// check activity server if the activity exists
+	_, err := b.activities.CheckActivity(ctx, activityname)
+	if err != nil {
+		return upstreamError(req, err)
+	}
+
+	reservations := make([]models.RoomReservation, 0, len(slots))
+	for _, slot := range slots {
+		reservations = append(reservations, models.RoomReservation{
+			RoomName:     slot.RoomName,
+			ActivityName: activityname,
+			Date:         slot.Date,
+			Day:          slot.Day,
+			Start:        slot.Start,
+			End:          slot.End,
+		})
+	}
+
+	sagaLock.Lock()
+	err = models.CreatePendingReservations(reservations)
+	if err == nil {
+		for i := range reservations {
+			sagas.Store(reservations[i].ID, true)
+		}
+	}
+	sagaLock.Unlock()
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+	pending := make([]*models.RoomReservation, 0, len(reservations))
+	requested := make([]client.Reservation, 0, len(reservations))
+	for i, slot := range slots {
+		pending = append(pending, &reservations[i])
+		defer sagas.Delete(reservations[i].ID)
+		requested = append(requested, client.Reservation{
+			RoomName:  slot.RoomName,
+			Date:      slot.Date,
+			Day:       slot.Day,
+			Start:     slot.Start,
+			End:       slot.End,
+			Hour:      slot.Hour,
+			Duration:  slot.Duration,
+			Reference: reservations[i].Reference,
+		})
+	}
+
+	// ask room server to book all of the rooms
+	booked, conflicts, err := b.rooms.ReserveBatch(ctx, requested)
+	if err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Status < 500 {
+			// the room server refused, there are no bookings to release
+			for _, reservation := range pending {
+				removePending(reservation)
+			}
+		} else {
+			// the rooms may have been booked before the call failed
+			b.compensate(pending...)
+		}
+		if len(conflicts) > 0 {
+			details := strings.Builder{}
+			details.WriteString("None of the rooms are reserved, these ones are not available:\r\n")
+			for _, conflict := range conflicts {
+				details.WriteString(fmt.Sprintf("%s %s-%s: %s\r\n", conflict.Reservation.RoomName,
+					conflict.Reservation.Start, conflict.Reservation.End, conflict.Reason))
+			}
+			return helper.CreateErrorResponseWithData(req, "Reservation", 403, "Forbidden", helper.ErrRoomReserved,
+				"Room not available.", details.String(), conflicts)
+		}
+		return upstreamError(req, err)
+	}
+	if len(booked) != len(reservations) {
+		b.compensate(pending...)
+		return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+			"Upstream error.", fmt.Sprintf("room server booked %d of the %d rooms", len(booked), len(reservations)))
+	}
+
+	// the room server fills the day of a dated reservation and the time of one given in whole hours
+	for i, booking := range booked {
+		reservations[i].Date = booking.Date
+		reservations[i].Day = booking.Day
+		reservations[i].Start = booking.Start
+		reservations[i].End = booking.End
+		reservations[i].RoomBookingID = booking.ID
+	}
+	err = models.ConfirmReservations(reservations)
+	if err != nil {
+		b.compensate(pending...)
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+
+	details := strings.Builder{}
+	for i := range reservations {
+		details.WriteString(reservationDetails(&reservations[i]))
+	}
+	return helper.CreateSuccessResponse(req, "Reservation", "Reservations successful.", details.String(), reservations)
+}
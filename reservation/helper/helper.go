@@ -6,7 +6,11 @@ import (
 )
 
 func CreateHTTPResponse(servername string, statuscode int, status string, title string, body string) string {
-	response := strings.Builder{}
+	return CreateHTTPResponseWithHeaders(servername, statuscode, status, title, body, nil)
+}
+
+// CreateHTTPResponseWithHeaders is CreateHTTPResponse with additional headers such as Allow.
+func CreateHTTPResponseWithHeaders(servername string, statuscode int, status string, title string, body string, headers map[string]string) string {
 	afterheader := strings.Builder{}
 	afterheader.WriteString("<!DOCTYPE html>\n")
 	afterheader.WriteString("<html lang=\"en\">\n")
@@ -28,33 +32,31 @@ func CreateHTTPResponse(servername string, statuscode int, status string, title
 	afterheader.WriteString("</p>\n")
 	afterheader.WriteString("</body>\n")
 
+	return createRawResponse(statuscode, status, "text/html", headers, afterheader.String())
+}
+
+// createRawResponse writes the status line and the headers in front of an already rendered body.
+func createRawResponse(statuscode int, status string, contenttype string, headers map[string]string, body string) string {
+	response := strings.Builder{}
 	response.WriteString("HTTP/1.0 ")
 	response.WriteString(strconv.Itoa(statuscode))
 	response.WriteString(" ")
 	response.WriteString(status)
 	response.WriteString("\r\n")
-	response.WriteString("Content-Type: text/html")
+	response.WriteString("Content-Type: ")
+	response.WriteString(contenttype)
 	response.WriteString("\r\n")
 	response.WriteString("Content-Length: ")
-	response.WriteString(strconv.Itoa(len([]byte(afterheader.String()))))
+	response.WriteString(strconv.Itoa(len([]byte(body))))
 	response.WriteString("\r\n")
+	for key, value := range headers {
+		response.WriteString(key)
+		response.WriteString(": ")
+		response.WriteString(value)
+		response.WriteString("\r\n")
+	}
 	response.WriteString("\r\n")
-	response.WriteString(afterheader.String())
+	response.WriteString(body)
 
 	return response.String()
 }
-
-func CreateHTTPRequest(url string) string {
-	request := strings.Builder{}
-	request.WriteString("GET ")
-	request.WriteString(url)
-	request.WriteString(" HTTP/1.0\r\n")
-
-	return request.String()
-}
-
-func GetHTMLPBody(s string) string {
-	start := strings.Index(s, "<p>")
-	end := strings.Index(s, "</p>")
-	return s[start+3 : end]
-}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/textproto"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// maxIdempotencyKeyLength bounds the keys the clients make up.
+const maxIdempotencyKeyLength = 255
+
+// acceptsJSON is a request asking for JSON, the responses kept for the retries are made for it
+// and for the HTML pages.
+var acceptsJSON = &helper.Request{Header: textproto.MIMEHeader{"Accept": {"application/json"}}}
+
+// reserveOnce makes the reservation unless a request with the same idempotency key was made
+// before, then the response of that one is replayed in the form the retry asks for.
+// Only a confirmed reservation is remembered, a request that failed can be tried again with
+// the same key.
+func (b *booker) reserveOnce(req *helper.Request, key string, body *models.RoomReservation) string {
+	if len(key) > maxIdempotencyKeyLength {
+		return helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", fmt.Sprintf("idempotency key should be at most %d characters", maxIdempotencyKeyLength))
+	}
+
+	// the parameters tell the retries apart from other requests that reuse the key
+	request := fmt.Sprintf("room=%s activity=%s date=%s day=%d start=%s end=%s hour=%d duration=%d",
+		body.RoomName, body.ActivityName, body.Date, body.Day, body.Start, body.End, body.Hour, body.Duration)
+
+	saved, claimed, err := models.ClaimIdempotencyKey(key, request)
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+	if !claimed {
+		if saved.Request != request {
+			return helper.CreateErrorResponse(req, "Reservation", 422, "Unprocessable Entity", helper.ErrIdempotencyKeyReused,
+				"Idempotency key reused.", "The idempotency key was used for another reservation.")
+		}
+		if saved.ReservationID == 0 {
+			return helper.CreateErrorResponse(req, "Reservation", 409, "Conflict", helper.ErrRequestInProgress,
+				"Request in progress.", "The reservation with this idempotency key is still being made.")
+		}
+		if req.WantsJSON() {
+			return saved.JSONResponse
+		}
+		return saved.HTMLResponse
+	}
+
+	// the key is saved with the reservation when it is confirmed, and kept from then on
+	reservation, response := b.reserve(req, body, saved)
+	if reservation != nil {
+		return response
+	}
+
+	// nothing was reserved, forget the key so the request can be made again
+	err = models.ReleaseIdempotencyKey(saved)
+	if err != nil {
+		log.Printf("Error: cannot release idempotency key %q: %+v", key, err)
+	}
+	return response
+}
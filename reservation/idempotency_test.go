@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// htmlRequest is a request that asks for an HTML answer.
+func htmlRequest(t *testing.T) *helper.Request {
+	t.Helper()
+
+	req, err := helper.ReadRequest(bufio.NewReader(strings.NewReader(
+		"POST /reserve HTTP/1.1\r\nAccept: text/html\r\nContent-Length: 0\r\n\r\n")))
+	if err != nil {
+		t.Fatalf("cannot make request: %v", err)
+	}
+	return req
+}
+
+// keysIn returns every idempotency key of the database.
+func keysIn(t *testing.T) []models.IdempotencyKey {
+	t.Helper()
+
+	keys := []models.IdempotencyKey{}
+	err := database.DBConn.Find(&keys).Error
+	if err != nil {
+		t.Fatalf("cannot find idempotency keys: %v", err)
+	}
+	return keys
+}
+
+func TestReserveOnceReplaysReservationInTheRetrysFormat(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	first := b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+	if status := statusOf(t, first); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, first)
+	}
+
+	retry := b.reserveOnce(htmlRequest(t), "key-1", weeklyReservation())
+	if status := statusOf(t, retry); status != 200 {
+		t.Fatalf("got status %d on retry, want 200: %s", status, retry)
+	}
+	if strings.Contains(retry, "application/json") {
+		t.Errorf("retry asking for HTML got the JSON of the first request: %s", retry)
+	}
+	if !strings.Contains(retry, "Reservation ID: 1\r\n") {
+		t.Errorf("retry does not describe the reservation: %s", retry)
+	}
+	if rooms.reserved != 1 {
+		t.Errorf("room server was asked to book %d times, want 1", rooms.reserved)
+	}
+}
+
+func TestReserveOnceReplaysOriginalResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(b *booker, reservation *models.RoomReservation) string
+	}{
+		{"after reschedule", func(b *booker, reservation *models.RoomReservation) string {
+			return b.reschedule(jsonRequest(t), reservation, &models.RoomReservation{
+				Start: models.ClockOf(12, 0),
+				End:   models.ClockOf(13, 0),
+			})
+		}},
+		{"after cancel", func(b *booker, reservation *models.RoomReservation) string {
+			return b.cancel(jsonRequest(t), reservation)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDatabase(t)
+			b := &booker{rooms: newFakeRooms(), activities: fakeActivities{}}
+
+			first := b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+			if status := statusOf(t, first); status != 200 {
+				t.Fatalf("got status %d, want 200: %s", status, first)
+			}
+			reservation, err := models.GetReservationByID(1)
+			if err != nil {
+				t.Fatalf("GetReservationByID: %v", err)
+			}
+			response := tt.change(b, reservation)
+			if status := statusOf(t, response); status != 200 {
+				t.Fatalf("got status %d, want 200: %s", status, response)
+			}
+
+			retry := b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+			if retry != first {
+				t.Errorf("got retry response %s, want the original %s", retry, first)
+			}
+		})
+	}
+}
+
+func TestReserveOnceSavesKeyWithConfirmation(t *testing.T) {
+	useTestDatabase(t)
+	b := &booker{rooms: newFakeRooms(), activities: fakeActivities{}}
+
+	b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+
+	// a restart forgets only the keys of the requests that never confirmed their reservation
+	err := models.ForgetUnfinishedIdempotencyKeys()
+	if err != nil {
+		t.Fatalf("ForgetUnfinishedIdempotencyKeys: %v", err)
+	}
+	keys := keysIn(t)
+	if len(keys) != 1 || keys[0].ReservationID != 1 {
+		t.Errorf("got keys %+v, want key-1 kept with reservation 1", keys)
+	}
+}
+
+func TestReserveOnceConfirmFailsReleasesKey(t *testing.T) {
+	useTestDatabase(t)
+	failOn(t, "BEFORE UPDATE OF status ON room_reservations WHEN NEW.status = 'confirmed'")
+	b := &booker{rooms: newFakeRooms(), activities: fakeActivities{}}
+
+	response := b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+	if status := statusOf(t, response); status != 500 {
+		t.Errorf("got status %d, want 500: %s", status, response)
+	}
+	if keys := keysIn(t); len(keys) != 0 {
+		t.Errorf("got keys %+v, want the key released so the request can be made again", keys)
+	}
+}
+
+func TestReserveOnceKeySaveFailsKeepsNoReservation(t *testing.T) {
+	useTestDatabase(t)
+	failOn(t, "BEFORE UPDATE OF reservation_id ON idempotency_keys")
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	response := b.reserveOnce(jsonRequest(t), "key-1", weeklyReservation())
+	if status := statusOf(t, response); status != 500 {
+		t.Errorf("got status %d, want 500: %s", status, response)
+	}
+	// the confirmation is rolled back with the key, so the reservation is compensated
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left without their key, want them removed", saved)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if keys := keysIn(t); len(keys) != 0 {
+		t.Errorf("got keys %+v, want the key released so the request can be made again", keys)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// The internal API is meant for the other servers, not for the browsers. It always
+// answers with a helper.APIResponse document in JSON, its data field holds:
+//
+//	POST /internal/v1/activities/rename  renamedActivity
+//
+// Clients should switch on the error field, the messages are for humans only.
+const internalAPIPrefix = "/internal/v1"
+
+// registerInternalAPI adds the version 1 internal endpoints to the router.
+func registerInternalAPI(router *helper.Router) {
+	router.Handle(internalAPIPrefix+"/health", internalHealth, "GET")
+	router.Handle(internalAPIPrefix+"/activities/rename", internalRenameActivity, "POST")
+}
+
+// writeInternal writes the response and closes the connection.
+func writeInternal(conn *net.Conn, response string) {
+	_, err := (*conn).Write([]byte(response))
+	if err != nil {
+		log.Printf("Error: %+v", err)
+	}
+	(*conn).Close()
+}
+
+func internalHealth(conn *net.Conn, req *helper.Request) {
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservation Server is healthy", nil))
+}
+
+// renamedActivity is the body of the rename endpoint, and its answer along with the number of
+// the reservations that were renamed.
+type renamedActivity struct {
+	Name         string `json:"activity_name"`
+	NewName      string `json:"new_name"`
+	Reservations int64  `json:"reservations"`
+}
+
+// internalRenameActivity is called by the activity server when it renames an activity, the
+// reservations of the activity take its new name.
+func internalRenameActivity(conn *net.Conn, req *helper.Request) {
+	var body renamedActivity
+	err := json.Unmarshal(req.Body, &body)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+	if body.Name == "" || body.NewName == "" {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidParameter,
+			"activity_name and new_name should be given", nil))
+
+		return
+	}
+
+	body.Reservations, err = models.RenameActivity(body.Name, body.NewName)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(500, "Internal Server Error", helper.ErrDatabase, err.Error(), nil))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservations renamed successfully", body))
+}
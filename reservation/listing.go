@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// HandleReservations lists the reservations matching the filters a page at a time, the
+// next_cursor of a page asks for the one after it.
+func HandleReservations(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		RoomName     string `json:"room_name"`
+		ActivityName string `json:"activity_name"`
+		Day          int    `json:"day"`
+		Date         string `json:"date"`
+		From         string `json:"from"`
+		To           string `json:"to"`
+		Hour         *int   `json:"hour"`
+		Sort         string `json:"sort"`
+		Cursor       string `json:"cursor"`
+		Limit        int    `json:"limit"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.RoomName = req.Query.Get("room")
+		body.ActivityName = req.Query.Get("activity")
+		body.Date = req.Query.Get("date")
+		body.From = req.Query.Get("from")
+		body.To = req.Query.Get("to")
+		body.Sort = req.Query.Get("sort")
+		body.Cursor = req.Query.Get("cursor")
+		var err error
+		if req.Query.Get("day") != "" {
+			body.Day, err = req.IntParam("day")
+		}
+		if err == nil && req.Query.Get("hour") != "" {
+			var hour int
+			hour, err = req.IntParam("hour")
+			body.Hour = &hour
+		}
+		if err == nil && req.Query.Get("limit") != "" {
+			body.Limit, err = req.IntParam("limit")
+		}
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
+
+			return
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
+
+				return
+			}
+		}
+	}
+
+	if body.Date != "" && (body.From != "" || body.To != "") {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", "give either a date or a from and to range")
+
+		return
+	}
+	query := &models.ReservationQuery{
+		RoomName:     body.RoomName,
+		ActivityName: body.ActivityName,
+		Day:          body.Day,
+		From:         body.From,
+		To:           body.To,
+		Hour:         body.Hour,
+		Sort:         body.Sort,
+		Cursor:       body.Cursor,
+		Limit:        body.Limit,
+	}
+	if body.Date != "" {
+		query.From = body.Date
+	}
+	err := query.Validate()
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", err.Error())
+
+		return
+	}
+
+	page, err := models.FindReservations(query)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Reservation", "Reservations.", pageDetails(req, page), page)
+}
+
+// pageDetails is the human readable listing of a page of reservations for the HTML pages.
+func pageDetails(req *helper.Request, page *models.ReservationPage) string {
+	details := strings.Builder{}
+	if len(page.Reservations) == 0 {
+		details.WriteString("No reservations found.\r\n")
+	}
+	for i := range page.Reservations {
+		res := &page.Reservations[i]
+		details.WriteString(fmt.Sprintf("Reservation ID %d: %s in %s, %s %s-%s\r\n", res.ID, res.ActivityName,
+			res.RoomName, dateOrEveryWeek(res.Date, res.Day), res.Start, res.End))
+	}
+	if page.NextCursor != "" && req.Method == "GET" {
+		next := req.Query
+		next.Set("cursor", page.NextCursor)
+		details.WriteString(fmt.Sprintf("\r\nNext page: %s?%s\r\n", req.Path, next.Encode()))
+	} else if page.NextCursor != "" {
+		details.WriteString(fmt.Sprintf("\r\nNext cursor: %s\r\n", page.NextCursor))
+	}
+	details.WriteString("\r\n")
+	return details.String()
+}
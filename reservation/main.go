@@ -1,7 +1,8 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,14 +11,25 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
 	"github.com/yusufatalay/SocketProgramming/reservation/helper"
 	"github.com/yusufatalay/SocketProgramming/reservation/models"
 )
 
-var ROOMSERVERPORT string
-var ACTIVITYSERVERPORT string
+// readTimeout is how long a client has to send its whole request.
+const readTimeout = 10 * time.Second
+
+// upstreamTimeout is how long the calls to the room and activity servers may take
+// while answering a single request.
+const upstreamTimeout = 5 * time.Second
+
+// clients of the internal API of the room and activity servers
+var roomClient *client.RoomClient
+var activityClient *client.ActivityClient
 
 func main() {
 
@@ -27,60 +39,71 @@ func main() {
 		log.Fatalf("cannot found project's dotenv file: %v\n", err)
 	}
 
-	ROOMSERVERPORT = os.Getenv("ROOMSERVERPORT")
-	ACTIVITYSERVERPORT = os.Getenv("ACTIVITYSERVERPORT")
-
-	// check if room server is active using health endpoint using the socket connection
-	// if not, exit the program
-	RoomServerConn, err := net.Dial("tcp", "localhost:"+ROOMSERVERPORT)
+	err = database.Connect("reservation.db")
 	if err != nil {
-		log.Fatalf("Cannor connect to Room Server %s", err.Error())
+		log.Fatal("Cannot connect to database:", err)
 	}
-
-	_, err = RoomServerConn.Write([]byte("GET /health HTTP/1.0"))
+	err = models.Migrate()
 	if err != nil {
 		log.Fatal(err)
 	}
-	buf := make([]byte, 1024)
-	_, err = RoomServerConn.Read(buf)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if !strings.Contains(string(buf), "200 OK") {
-		fmt.Println("Room server is not active.")
 
-		return
-	}
-	RoomServerConn.Close()
-	// check if activity server is active using health endpoint using the socket connection
-	// if not, exit the program
-	ActivityServerConn, err := net.Dial("tcp", "localhost:"+ACTIVITYSERVERPORT)
-	if err != nil {
-		log.Fatalf("Cannot connect Activity Server %s", err.Error())
-	}
-	_, err = ActivityServerConn.Write([]byte("GET /health HTTP/1.0"))
-	if err != nil {
-		log.Fatal(err)
+	// responses are replayed for a day unless the deployment configures another retention
+	if os.Getenv("IDEMPOTENCYHOURS") != "" {
+		err = models.SetIdempotencyRetention(os.Getenv("IDEMPOTENCYHOURS"))
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	buf = make([]byte, 1024)
-	_, err = ActivityServerConn.Read(buf)
+	roomClient = client.NewRoomClient("localhost:" + os.Getenv("ROOMSERVERPORT"))
+	activityClient = client.NewActivityClient("localhost:" + os.Getenv("ACTIVITYSERVERPORT"))
+	bookings = &booker{rooms: roomClient, activities: activityClient}
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// check if room server is active using health endpoint
+	// if not, exit the program
+	err = roomClient.Health(ctx)
 	if err != nil {
+		fmt.Printf("Room server is not active: %s\n", err.Error())
 
-		log.Fatal(err)
+		return
 	}
-	if !strings.Contains(string(buf), "200 OK") {
-		fmt.Println("Activity server is not active.")
+	// check if activity server is active using health endpoint
+	// if not, exit the program
+	err = activityClient.Health(ctx)
+	if err != nil {
+		fmt.Printf("Activity server is not active: %s\n", err.Error())
 
 		return
 	}
-	ActivityServerConn.Close()
 
 	if len(os.Args) != 2 {
 		// nolint
 		panic(errors.New("Should only provide a port number."))
 	}
 
+	// insert this server's port number to config file, keeping the other settings in it,
+	// the activity server calls back to it when an activity is renamed
+	config, err := godotenv.Read("../.env")
+	if err != nil {
+		log.Fatalf("cannot read project's dotenv file: %v\n", err)
+	}
+	config["RESERVATIONSERVERPORT"] = os.Args[1]
+	err = godotenv.Write(config, "../.env")
+	if err != nil {
+		log.Fatalf("cannot write project's dotenv file: %v\n", err)
+	}
+
+	// clean up after the reservations whose saga did not finish
+	err = models.ForgetUnfinishedIdempotencyKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go bookings.compensatePendingReservations()
+
 	// create a tcp socket that listens localhost:PORT
 	ln, err := net.Listen("tcp", "localhost:"+os.Args[1])
 	if err != nil {
@@ -89,6 +112,21 @@ func main() {
 	// deferred close the connection
 	defer ln.Close()
 
+	// every endpoint is matched exactly, with the methods it supports
+	router := helper.NewRouter("Reservation")
+	router.Handle("/reserve", HandleReserve, "GET", "POST")
+	router.Handle("/listavailibility", HandleListAvailability, "GET", "POST")
+	router.Handle("/display", HandleDisplay, "GET", "POST")
+	router.Handle("/cancel", HandleCancel, "GET", "POST")
+	router.Handle("/reschedule", HandleReschedule, "GET", "POST")
+	router.Handle("/reserveseries", HandleReserveSeries, "GET", "POST")
+	router.Handle("/cancelseries", HandleCancelSeries, "GET", "POST")
+	router.Handle("/reservegroup", HandleReserveGroup, "GET", "POST")
+	router.Handle("/reservations", HandleReservations, "GET", "POST")
+	router.Handle("/search", HandleSearch, "GET", "POST")
+	router.Handle("/reconcile", HandleReconcile, "GET", "POST")
+	registerInternalAPI(router)
+
 	//	program loop
 	for {
 		// accept connection
@@ -97,513 +135,575 @@ func main() {
 			log.Fatal(err)
 		}
 		// create new thread for each connection request to handle concurrency
-		go handleConnection(conn)
+		go handleConnection(conn, router)
 	}
 
 }
 
-func handleConnection(conn net.Conn) {
+func handleConnection(conn net.Conn, router *helper.Router) {
+	// give slow or stuck clients a limited time to send their request
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
 
-	// Use bufio to read the request
-	buf := make([]byte, 1024)
-	_, err := conn.Read(buf)
+	// Parse the request
+	req, err := helper.ReadRequest(bufio.NewReader(conn))
 	if err != nil {
-		log.Fatal(err)
+		response := helper.CreateErrorResponse(nil, "Reservation", 400, "Bad Request", helper.ErrMalformedRequest,
+			"Malformed Request", err.Error())
+		conn.Write([]byte(response))
+		conn.Close()
 
 		return
 	}
 
-	// Parse the request
-
-	reqStr := string(buf)
-	reqParts := strings.Split(reqStr, "\n")
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-
-	if strings.HasPrefix(url, "/reserve") {
-		HandleReserve(&conn, reqStr)
-
-	} else if strings.HasPrefix(url, "/listavailibility") {
-		HandleListAvailability(&conn, reqStr)
-
-	} else if strings.HasPrefix(url, "/display") {
-		HandleDisplay(&conn, reqStr)
-
-	}
+	// use appropriate handler for the url
+	router.Serve(&conn, req)
 }
 
-func HandleReserve(conn *net.Conn, req string) {
-	ActivityServerConn, err := net.Dial("tcp", "localhost:"+ACTIVITYSERVERPORT)
-	if err != nil {
-		log.Fatalf("Cannot Connect Activity Server %s", err.Error())
-	}
-
-	RoomServerConn, err := net.Dial("tcp", "localhost:"+ROOMSERVERPORT)
-	if err != nil {
-		log.Fatalf("Cannot Connect Room Server %s", err.Error())
-	}
-
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
+func HandleReserve(conn *net.Conn, req *helper.Request) {
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("Error: %+v", err)
 		}
-		ActivityServerConn.Close()
-		RoomServerConn.Close()
 		(*conn).Close()
 	}()
-	switch method {
+
+	var body struct {
+		models.RoomReservation
+		IdempotencyKey string `json:"idempotency_key"`
+	}
+	var err error
+	switch req.Method {
 	case "GET":
 		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
-		}
-		// check activity server if the activity exists make a socket request
-		req := helper.CreateHTTPRequest(fmt.Sprintf("/check?name=%s", paramsMap["activity"]))
-		_, err := ActivityServerConn.Write([]byte(req))
-		if err != nil {
-			log.Fatal(err)
+		body.RoomName = req.Query.Get("room")
+		body.ActivityName = req.Query.Get("activity")
+		// a date books that date only, a day of the week books it every week
+		body.Date = req.Query.Get("date")
+		body.IdempotencyKey = req.Query.Get("idempotency_key")
+		if body.Date == "" {
+			body.Day, err = req.IntParam("day")
 		}
-		buf := make([]byte, 1024)
-		_, err = ActivityServerConn.Read(buf)
-		if err != nil {
-			if err.Error() != "EOF" {
-				log.Fatal(err)
-			}
+		if err == nil {
+			err = readTimeParams(req, &body.RoomReservation)
 		}
-		if !strings.Contains(string(buf), "200 OK") {
-			response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-				"Database error.", "Activity not found.")
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser error.", err.Error())
 
 			return
 		}
-		// check room server if the input is valid
-		req = helper.CreateHTTPRequest(fmt.Sprintf("/reserve?name=%s&day=%s&hour=%s&duration=%s",
-			paramsMap["room"], paramsMap["day"], paramsMap["hour"], paramsMap["duration"]))
-
-		_, err = RoomServerConn.Write([]byte(req))
-		if err != nil {
-			if err.Error() != "EOF" {
-				log.Fatal(err)
-			}
-		}
-		buf = make([]byte, 1024)
-		_, err = RoomServerConn.Read(buf)
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			log.Fatal(err)
-		}
-		if strings.Contains(string(buf), "400 Bad Request") {
-			response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-				"Database error.", "Invalid input.")
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser error.", err.Error())
 
 			return
 		}
+	}
 
-		if strings.Contains(string(buf), "404 Not Found") {
-			response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-				"Database error.", "Room not found.")
+	// the retries of a request made with an idempotency key get the response of the first one
+	key := req.Header.Get("Idempotency-Key")
+	if key == "" {
+		key = body.IdempotencyKey
+	}
+	if key != "" {
+		response = bookings.reserveOnce(req, key, &body.RoomReservation)
 
-			return
+		return
+	}
+
+	_, response = bookings.reserve(req, &body.RoomReservation, nil)
+}
+
+// readTimeParams reads the time of a reservation from the start and end parameters, or
+// from the hour and duration parameters when it is given in whole hours.
+func readTimeParams(req *helper.Request, reservation *models.RoomReservation) error {
+	if req.Query.Get("start") == "" && req.Query.Get("end") == "" {
+		var err error
+		reservation.Hour, err = req.IntParam("hour")
+		if err != nil {
+			return err
 		}
+		reservation.Duration, err = req.IntParam("duration")
+		return err
+	}
 
-		if strings.Contains(string(buf), "403 Forbidden") {
-			response = helper.CreateHTTPResponse("Reservation", 403, "Forbidden",
-				"Database error.", "Room not available.")
+	var err error
+	reservation.Start, err = models.ParseClock(req.Query.Get("start"))
+	if err != nil {
+		return errors.New("start parameter: " + err.Error())
+	}
+	reservation.End, err = models.ParseClock(req.Query.Get("end"))
+	if err != nil {
+		return errors.New("end parameter: " + err.Error())
+	}
+	return nil
+}
 
-			return
+// upstreamError turns an error of the room or activity server into the response of this server.
+func upstreamError(req *helper.Request, err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return helper.CreateErrorResponse(req, "Reservation", 504, "Gateway Timeout", helper.ErrUpstream,
+			"Upstream error.", "Upstream server did not answer in time.")
+	}
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+			"Upstream error.", err.Error())
+	}
+
+	switch apiErr.Code {
+	case client.CodeActivityNotFound:
+		return helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrActivityNotFound,
+			"Database error.", "Activity not found.")
+	case client.CodeRoomNotFound:
+		return helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrRoomNotFound,
+			"Database error.", "Room not found.")
+	case client.CodeRoomReserved:
+		return helper.CreateErrorResponse(req, "Reservation", 403, "Forbidden", helper.ErrRoomReserved,
+			"Database error.", "Room not available.")
+	case client.CodeRoomBlackedOut:
+		return helper.CreateErrorResponse(req, "Reservation", 403, "Forbidden", helper.ErrRoomBlackedOut,
+			"Database error.", "Room is blacked out.")
+	case client.CodeBuildingNotFound:
+		return helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrBuildingNotFound,
+			"Database error.", "Building not found.")
+	case client.CodeValidation, client.CodeInvalidParameter, client.CodeInvalidBody:
+		return helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", "Invalid input: "+apiErr.Message)
+	default:
+		return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+			"Upstream error.", apiErr.Error())
+	}
+}
+
+func HandleListAvailability(conn *net.Conn, req *helper.Request) {
+	response := ""
+
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
 		}
-		if strings.Contains(string(buf), "200 OK") {
-			// successful now create local reservation
-			dayint, _ := strconv.Atoi(paramsMap["day"])
-			hourint, _ := strconv.Atoi(paramsMap["hour"])
-			durationint, _ := strconv.Atoi(paramsMap["duration"])
-			resID, err := models.CreateRoomReservation(&models.RoomReservation{
-				RoomName:     paramsMap["room"],
-				ActivityName: paramsMap["activity"],
-				Day:          dayint,
-				Hour:         hourint,
-				Duration:     durationint,
-			})
+		(*conn).Close()
+	}()
 
+	var body struct {
+		Name string `json:"room_name"`
+		Day  int    `json:"day"`
+		Date string `json:"date"`
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("room")
+		body.Date = req.Query.Get("date")
+		body.From = req.Query.Get("from")
+		body.To = req.Query.Get("to")
+		// the day parameter is optional
+		if req.Query.Get("day") != "" {
+			var err error
+			body.Day, err = req.IntParam("day")
 			if err != nil {
-				log.Fatal(err)
-			}
+				response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+					"Parser Error", err.Error())
 
-			response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-				"Reservation successful.",
-				fmt.Sprintf("Reservation Details:\r\nReservation ID: %d\r\nRoom: %s\r\nActivity: %s\r\nDay: %d\r\nHour: %d\r\nDuration: %d\r\n\r\n",
-					resID, paramsMap["room"], paramsMap["activity"], dayint, hourint, durationint))
+				return
+			}
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
 			return
 		}
-	case "POST":
-		// POST request has been made, read the request body
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", "room parameter is empty")
 
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //       // unmarshall the json body to a struct
-		var body models.RoomReservation
+		return
+	}
 
-		err := json.Unmarshal(reqBodyByte, &body)
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// list a date range, a date, a day of the week, or else the whole week
+	hoursStr := strings.Builder{}
+	var data interface{}
+	switch {
+	case body.From != "" || body.To != "":
+		dates, err := roomClient.RangeAvailability(ctx, body.Name, body.From, body.To)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-				"Parser error.", err.Error())
+			response = upstreamError(req, err)
 
 			return
 		}
-		// check activity server if the activity exists make a socket request
-		req = helper.CreateHTTPRequest(fmt.Sprintf("/check?name=%s", body.ActivityName))
-		_, err = ActivityServerConn.Write([]byte(req))
-		if err != nil {
-			log.Fatal(err)
+		for _, day := range dates.Days {
+			hoursStr.WriteString(day.Date + ": ")
+			writeSlots(&hoursStr, &day)
 		}
-		buf := make([]byte, 1024)
-		_, err = ActivityServerConn.Read(buf)
+		data = dates
+	case body.Date != "":
+		daily, err := roomClient.CheckDateAvailability(ctx, body.Name, body.Date)
 		if err != nil {
-			log.Fatal(err)
-		}
-		if !strings.Contains(string(buf), "200 OK") {
-			response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-				"Database error.", "Activity not found.")
+			response = upstreamError(req, err)
 
 			return
 		}
-
-		// check room server if the input is valid
-		req = helper.CreateHTTPRequest(fmt.Sprintf("/check?name=%s&day=%d&hour=%d&duration=%d",
-			body.RoomName, body.Day, body.Hour, body.Duration))
-		_, err = RoomServerConn.Write([]byte(req))
+		writeSlots(&hoursStr, daily)
+		data = daily
+	case body.Day != 0:
+		daily, err := roomClient.CheckAvailability(ctx, body.Name, body.Day)
 		if err != nil {
-			log.Fatal(err)
-		}
-		buf = make([]byte, 1024)
-		_, err = RoomServerConn.Read(buf)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if strings.Contains(string(buf), "400 Bad Request") {
-			response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-				"Parser error.", "Invalid input.")
-			return
-		}
-		if strings.Contains(string(buf), "404 Not Found") {
-			response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-				"Database error.", "Room not found.")
+			response = upstreamError(req, err)
 
 			return
 		}
+		writeSlots(&hoursStr, daily)
+		data = daily
+	default:
+		weekly, err := roomClient.WeeklyAvailability(ctx, body.Name)
+		if err != nil {
+			response = upstreamError(req, err)
 
-		if strings.Contains(string(buf), "403 Forbidden") {
-			response = helper.CreateHTTPResponse("Reservation", 403, "Forbidden",
-				"Database error.", "Room is already reserved.")
 			return
 		}
-
-		if strings.Contains(string(buf), "200 OK") {
-			// successful now create local reservation
-			resID, err := models.CreateRoomReservation(&models.RoomReservation{
-				RoomName:     body.RoomName,
-				ActivityName: body.ActivityName,
-				Day:          body.Day,
-				Hour:         body.Hour,
-				Duration:     body.Duration,
-			})
-
-			if err != nil {
-				log.Fatal(err)
-			}
-			response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-				"Reservation successful.", fmt.Sprintf("Reservation Details:\r\nReservation ID: %d\r\nRoom: %s\r\nActivity: %s\r\nDay: %d\r\nHour: %d\r\nDuration: %d\r\n\r\n",
-					resID, body.RoomName, body.ActivityName, body.Day, body.Hour, body.Duration))
-
-			return
+		for _, day := range weekly.Days {
+			hoursStr.WriteString(fmt.Sprintf("day %d: ", day.Day))
+			writeSlots(&hoursStr, &day)
 		}
+		data = weekly
 	}
+
+	response = helper.CreateSuccessResponse(req, "Reservation",
+		"Available Days", hoursStr.String(), data)
 }
 
-func HandleListAvailability(conn *net.Conn, req string) {
-	ActivityServerConn, err := net.Dial("tcp", "localhost:"+ACTIVITYSERVERPORT)
-	if err != nil {
-		log.Fatalf("Cannot Connect Activity Server %s", err.Error())
+// writeSlots writes the free slots of the day in a single line, the way the room server lists them.
+func writeSlots(sb *strings.Builder, day *client.DayAvailability) {
+	if day.Closed {
+		sb.WriteString("closed")
 	}
-
-	RoomServerConn, err := net.Dial("tcp", "localhost:"+ROOMSERVERPORT)
-	if err != nil {
-		log.Fatalf("Cannot Connect Room Server %s", err.Error())
+	for _, slot := range day.Slots {
+		sb.WriteString(slot.String() + " ")
 	}
+	sb.WriteRune('\n')
+}
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
+func HandleDisplay(conn *net.Conn, req *helper.Request) {
 	response := ""
 
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("Error: %+v", err)
 		}
-		ActivityServerConn.Close()
-		RoomServerConn.Close()
 		(*conn).Close()
 	}()
 
-	switch method {
+	var body struct {
+		ID int `json:"id"`
+	}
+	switch req.Method {
 	case "GET":
 		// GET request has been made, read the query params from the URL
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
-
-		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
-		}
-		// return error if roomname has not given
-		if _, ok := paramsMap["room"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "room parameter is empty")
+		idint, err := strconv.Atoi(req.Query.Get("id"))
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", "ID should be integer")
 
 			return
 		}
-		// if the day parameter does not exists call the list all availability function
-		if day, ok := paramsMap["day"]; ok {
-			req := helper.CreateHTTPRequest(fmt.Sprintf("/checkavailability?name=%s&day=%s",
-				paramsMap["room"], day))
-
-			_, err = RoomServerConn.Write([]byte(req))
-			if err != nil {
-				log.Fatal(err)
-			}
-			buf := make([]byte, 1024)
-			_, err = RoomServerConn.Read(buf)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if strings.Contains(string(buf), "200 OK") {
-				response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-					"Available Days", helper.GetHTMLPBody(string(buf)))
+		body.ID = idint
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
-				return
-			} else if strings.Contains(string(buf), "400 Bad Request") {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
+			return
+		}
+	}
 
-				return
-			} else if strings.Contains(string(buf), "404 Not Found") {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Room not found.")
+	res, err := models.GetReservationByID(uint(body.ID))
+	if err != nil {
+		if err.Error() == "RoomReservation does not exists" {
+			response = helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrReservationNotFound,
+				"Database error.", "Reservation not found.")
 
-				return
-			}
+			return
 		} else {
-			req := helper.CreateHTTPRequest(fmt.Sprintf("/checkweeklyavailability?name=%s",
-				paramsMap["room"]))
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", "Invalid input")
 
-			_, err = RoomServerConn.Write([]byte(req))
-			if err != nil {
-				log.Fatal(err)
-			}
-			buf := make([]byte, 1024)
-			_, err = RoomServerConn.Read(buf)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if strings.Contains(string(buf), "200 OK") {
-				response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-					"Available Days", helper.GetHTMLPBody(string(buf)))
+			return
+		}
+	}
 
-				return
-			} else if strings.Contains(string(buf), "400 Bad Request") {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
+	response = helper.CreateSuccessResponse(req, "Reservation",
+		"Reservation successful.", reservationDetails(res), res)
+}
 
-				return
-			} else if strings.Contains(string(buf), "404 Not Found") {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Room not found.")
+func HandleCancel(conn *net.Conn, req *helper.Request) {
+	response := ""
 
-				return
-			}
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
 		}
+		(*conn).Close()
+	}()
 
-	case "POST":
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-		var body struct {
-			Name string `json:"room_name"`
-			Day  int    `json:"day"`
-		}
+	var body struct {
+		ID int `json:"id"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		idint, err := strconv.Atoi(req.Query.Get("id"))
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", "ID should be integer")
 
-		err := json.Unmarshal(reqBodyByte, &body)
+			return
+		}
+		body.ID = idint
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
 				"Parser Error", err.Error())
 
 			return
 		}
+	}
 
-		// if day value does not in the request then make another request
-		// if the day parameter does not exists call the list all availability function
-		if body.Day != 0 {
-			req := helper.CreateHTTPRequest(fmt.Sprintf("/checkavailability?name=%s&day=%d",
-				body.Name, body.Day))
-
-			_, err = RoomServerConn.Write([]byte(req))
-			if err != nil {
-				log.Fatal(err)
-			}
-			buf := make([]byte, 1024)
-			_, err = RoomServerConn.Read(buf)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if strings.Contains(string(buf), "200 OK") {
-				response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-					"Available Days", helper.GetHTMLPBody(string(buf)))
-
-				return
-			} else if strings.Contains(string(buf), "400 Bad Request") {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
-
-				return
-			} else if strings.Contains(string(buf), "404 Not Found") {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Room not found.")
-
-				return
-			}
+	res, err := models.GetReservationByID(uint(body.ID))
+	if err != nil {
+		if err.Error() == "RoomReservation does not exists" {
+			response = helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrReservationNotFound,
+				"Database error.", "Reservation not found.")
 		} else {
-			req := helper.CreateHTTPRequest(fmt.Sprintf("/checkweeklyavailability?name=%s",
-				body.Name))
-
-			_, err = RoomServerConn.Write([]byte(req))
-			if err != nil {
-				log.Fatal(err)
-			}
-			buf := make([]byte, 1024)
-			_, err = RoomServerConn.Read(buf)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if strings.Contains(string(buf), "200 OK") {
-				response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-					"Available Days", helper.GetHTMLPBody(string(buf)))
+			response = helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database error.", err.Error())
+		}
 
-				return
-			} else if strings.Contains(string(buf), "400 Bad Request") {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
+		return
+	}
 
-				return
-			} else if strings.Contains(string(buf), "404 Not Found") {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Room not found.")
+	response = bookings.cancel(req, res)
+}
 
-				return
-			}
-		}
+// cancel releases the room booking of the reservation and removes the reservation.
+func (b *booker) cancel(req *helper.Request, res *models.RoomReservation) string {
+	// keep a reconciliation from seeing the room server changed but not the reservations
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// free the time on the room server first, the reservation stays if that fails. A booking
+	// whose id is outdated is found by its reference, and the reservations made before the
+	// room server's ids were kept are found by their time.
+	var released []uint
+	var err error
+	if res.RoomBookingID != 0 {
+		released, err = b.rooms.Release(ctx, []uint{res.RoomBookingID})
+	}
+	if err == nil && len(released) == 0 && res.Reference != "" {
+		_, err = b.rooms.ReleaseReferences(ctx, res.Reference)
+	} else if err == nil && len(released) == 0 && res.RoomBookingID == 0 {
+		_, err = b.rooms.ReleaseMatching(ctx, []client.Reservation{{
+			RoomName: res.RoomName,
+			Date:     res.Date,
+			Day:      res.Day,
+			Start:    res.Start,
+			End:      res.End,
+		}})
+	}
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+			"Cancellation failed.", "Room server could not release the reservation: "+err.Error())
+	}
 
+	err = models.RemoveReservation(res)
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
 	}
+
+	return helper.CreateSuccessResponse(req, "Reservation",
+		"Cancellation successful.", reservationDetails(res), res)
 }
 
-func HandleDisplay(conn *net.Conn, req string) {
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
+// HandleReschedule moves a reservation to another room, date or day and time in one step,
+// the parts that are not given are kept.
+func HandleReschedule(conn *net.Conn, req *helper.Request) {
 	response := ""
 
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("Error: %+v", err)
 		}
 		(*conn).Close()
 	}()
 
-	switch method {
+	var body models.RoomReservation
+	switch req.Method {
 	case "GET":
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
 		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
-
-		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
-		}
-		idint, err := strconv.Atoi(paramsMap["id"])
+		id, err := req.IntParam("id")
+		if err == nil {
+			body.ID = uint(id)
+			body.RoomName = req.Query.Get("room")
+			body.Date = req.Query.Get("date")
+			if req.Query.Get("day") != "" {
+				body.Day, err = req.IntParam("day")
+			}
+		}
+		if err == nil && (req.Query.Get("start") != "" || req.Query.Get("end") != "" || req.Query.Get("hour") != "") {
+			err = readTimeParams(req, &body)
+		}
 		if err != nil {
-			response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-				"Parser Error", "ID should be integer")
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
 			return
 		}
-		res, err := models.GetReservationByID(uint(idint))
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			if err.Error() == "RoomReservation does not exists" {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Reservation not found.")
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
+			return
+		}
+	}
 
-				return
-			}
+	res, err := models.GetReservationByID(body.ID)
+	if err != nil {
+		if err.Error() == "RoomReservation does not exists" {
+			response = helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrReservationNotFound,
+				"Database error.", "Reservation not found.")
+		} else {
+			response = helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database error.", err.Error())
 		}
 
-		response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-			"Reservation successful.", fmt.Sprintf("Reservation Details:\r\nReservation ID: %d\r\nRoom: %s\r\nActivity: %s\r\nDay: %d\r\nHour: %d\r\nDuration: %d\r\n\r\n",
-				res.ID, res.RoomName, res.ActivityName, res.Day, res.Hour, res.Duration))
+		return
+	}
 
-	case "POST":
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-		var body struct {
-			ID int `json:"id"`
-		}
+	response = bookings.reschedule(req, res, &body)
+}
 
-		err := json.Unmarshal(reqBodyByte, &body)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", err.Error())
+// reschedule moves the reservation to the room, date or day and time of the body, the ones
+// left empty are kept.
+func (b *booker) reschedule(req *helper.Request, res *models.RoomReservation, body *models.RoomReservation) string {
+	// the old booking on the room server, the reservations made before the room server's ids
+	// were kept are found by their time
+	old := client.Reservation{
+		ID:        res.RoomBookingID,
+		RoomName:  res.RoomName,
+		Date:      res.Date,
+		Day:       res.Day,
+		Start:     res.Start,
+		End:       res.End,
+		Reference: res.Reference,
+	}
+	replacement := old
+	replacement.ID = 0
+	if body.RoomName != "" {
+		replacement.RoomName = body.RoomName
+	}
+	if body.Date != "" || body.Day != 0 {
+		replacement.Date = body.Date
+		replacement.Day = body.Day
+	}
+	if body.Start != 0 || body.End != 0 || body.Hour != 0 {
+		replacement.Start = body.Start
+		replacement.End = body.End
+		replacement.Hour = body.Hour
+		replacement.Duration = body.Duration
+	}
 
-			return
-		}
-		res, err := models.GetReservationByID(uint(body.ID))
-		if err != nil {
-			if err.Error() == "RoomReservation does not exists" {
-				response = helper.CreateHTTPResponse("Reservation", 404, "Not Found",
-					"Database error.", "Reservation not found.")
+	// keep a reconciliation from seeing the room server changed but not the reservations
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Reservation", 400, "Bad Request",
-					"Parser Error", "Invalid input")
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
 
-				return
-			}
+	// the room server frees the old time only if the new one can be booked
+	booked, err := b.rooms.Swap(ctx, old, replacement)
+	if err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Code == client.CodeReservationNotFound {
+			return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+				"Reschedule failed.", "Room server does not have the reservation.")
 		}
+		return upstreamError(req, err)
+	}
 
-		response = helper.CreateHTTPResponse("Reservation", 200, "OK",
-			"Reservation successful.", fmt.Sprintf("Reservation Details:\r\nReservation ID: %d\r\nRoom: %s\r\nActivity: %s\r\nDay: %d\r\nHour: %d\r\nDuration: %d\r\n\r\n",
-				res.ID, res.RoomName, res.ActivityName, res.Day, res.Hour, res.Duration))
+	previous := *res
+	res.RoomName = booked.RoomName
+	res.Date = booked.Date
+	res.Day = booked.Day
+	res.Start = booked.Start
+	res.End = booked.End
+	res.RoomBookingID = booked.ID
+	err = models.UpdateReservation(res)
+	if err != nil {
+		// put the old time back, the reservation keeps it along with the restored booking's id
+		*res = previous
+		restored, swapErr := b.rooms.Swap(ctx, *booked, old)
+		if swapErr != nil {
+			log.Printf("Error: %+v", swapErr)
+		} else {
+			res.RoomBookingID = restored.ID
+			swapErr = models.UpdateReservation(res)
+			if swapErr != nil {
+				log.Printf("Error: reservation %d keeps the outdated room booking id %d: %+v", res.ID, previous.RoomBookingID, swapErr)
+			}
+		}
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
 	}
 
+	return helper.CreateSuccessResponse(req, "Reservation", "Reschedule successful.",
+		fmt.Sprintf("Moved from %s %s-%s in %s.\r\n\r\n", dateOrEveryWeek(previous.Date, previous.Day), previous.Start, previous.End,
+			previous.RoomName)+reservationDetails(res), res)
+}
+
+// reservationDetails is the human readable listing of a reservation for the HTML pages.
+func reservationDetails(res *models.RoomReservation) string {
+	date := dateOrEveryWeek(res.Date, res.Day)
+	return fmt.Sprintf("Reservation Details:\r\nReservation ID: %d\r\nRoom: %s\r\nActivity: %s\r\nDate: %s\r\nDay: %d\r\nStart: %s\r\nEnd: %s\r\n\r\n",
+		res.ID, res.RoomName, res.ActivityName, date, res.Day, res.Start, res.End)
+}
+
+// dateOrEveryWeek is the date of a reservation or a room booking, one without a date recurs
+// every week on its day.
+func dateOrEveryWeek(date string, day int) string {
+	if date == "" {
+		return fmt.Sprintf("every week on day %d", day)
+	}
+	return date
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+func TestRescheduleUpdateFailsRestoresBooking(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, _ := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if reservation == nil {
+		t.Fatal("reserve failed")
+	}
+	failOn(t, "BEFORE UPDATE ON room_reservations WHEN NEW.starts_at <> OLD.starts_at")
+
+	response := b.reschedule(jsonRequest(t), reservation, &models.RoomReservation{
+		Start: models.ClockOf(12, 0),
+		End:   models.ClockOf(13, 0),
+	})
+	if status := statusOf(t, response); status != 500 {
+		t.Fatalf("got status %d, want 500: %s", status, response)
+	}
+
+	booking, ok := rooms.booked()[reservation.Reference]
+	if !ok {
+		t.Fatalf("room is not booked with reference %q after the rollback", reservation.Reference)
+	}
+	if booking.Start != models.ClockOf(10, 0) {
+		t.Errorf("got booking at %s, want the old time 10:00 back", booking.Start)
+	}
+	saved := reservationsIn(t)
+	if len(saved) != 1 || saved[0].Start != models.ClockOf(10, 0) {
+		t.Fatalf("got reservations %+v, want the one at 10:00", saved)
+	}
+	if saved[0].RoomBookingID != booking.ID {
+		t.Errorf("got room booking id %d, want the restored booking's %d", saved[0].RoomBookingID, booking.ID)
+	}
+
+	// the reservation can still be cancelled along with its booking
+	response = b.cancel(jsonRequest(t), &saved[0])
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+}
+
+func TestCancelReleasesOutdatedBookingByReference(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, _ := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if reservation == nil {
+		t.Fatal("reserve failed")
+	}
+	// the room server does not have a booking with this id anymore
+	reservation.RoomBookingID = 999
+	err := models.UpdateReservation(reservation)
+	if err != nil {
+		t.Fatalf("UpdateReservation: %v", err)
+	}
+
+	response := b.cancel(jsonRequest(t), reservation)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released by reference", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the cancelled one removed", saved)
+	}
+}
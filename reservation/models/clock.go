@@ -0,0 +1,20 @@
+package models
+
+import "github.com/yusufatalay/SocketProgramming/reservation/client"
+
+// Clock is a time of day in minutes after midnight, it is written as "15:04" in JSON. It is the
+// clock of the room server's client, so the times go back and forth without being converted.
+type Clock = client.Clock
+
+// EndOfDay is the midnight at the end of a day, it is written as "24:00".
+const EndOfDay = client.EndOfDay
+
+// ClockOf returns the clock at the given hour and minute.
+func ClockOf(hour int, minute int) Clock {
+	return client.ClockOf(hour, minute)
+}
+
+// ParseClock parses a time of day such as 09:30, or 24:00 for the end of the day.
+func ParseClock(clock string) (Clock, error) {
+	return client.ParseClock(clock)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+)
+
+// IdempotencyRetention is how long the responses of the requests made with an idempotency key
+// are replayed. It is a day unless the deployment configures another one.
+var IdempotencyRetention = 24 * time.Hour
+
+// idempotencyLock serializes the claims of the keys, every connection is served in its own goroutine.
+var idempotencyLock sync.Mutex
+
+// IdempotencyKey is a key a client sent along with a request so that the retries of the request
+// get the first response instead of being made again. Request holds the parameters of the
+// request to tell the retries from other requests that reuse the key. ReservationID is 0 while
+// the request is being made, afterwards it is the reservation the request made. JSONResponse
+// and HTMLResponse are its response in the two forms a request can ask for, as it was made, so
+// the retries get it even after the reservation is rescheduled or cancelled.
+type IdempotencyKey struct {
+	Key           string `gorm:"primaryKey"`
+	Request       string `gorm:"not null"`
+	ReservationID uint   `gorm:"not null;default:0"`
+	JSONResponse  string `gorm:"not null;default:''"`
+	HTMLResponse  string `gorm:"not null;default:''"`
+	CreatedAt     time.Time
+}
+
+// SetIdempotencyRetention configures how long the keys are kept from its setting, in hours.
+func SetIdempotencyRetention(setting string) error {
+	hours, err := strconv.Atoi(setting)
+	if err != nil || hours < 1 {
+		return fmt.Errorf("idempotency key retention should be a positive number of hours, not %q", setting)
+	}
+	IdempotencyRetention = time.Duration(hours) * time.Hour
+	return nil
+}
+
+// ClaimIdempotencyKey saves the key for the request that is about to be made with it and
+// reports true. When the key is already saved it reports false and returns the saved one
+// instead, which may still be without a reservation. Keys older than IdempotencyRetention are
+// forgotten.
+func ClaimIdempotencyKey(key string, request string) (*IdempotencyKey, bool, error) {
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+
+	err := database.DBConn.Where("created_at < ?", time.Now().Add(-IdempotencyRetention)).Delete(&IdempotencyKey{}).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, false, err
+	}
+
+	saved := []IdempotencyKey{}
+	err = database.DBConn.Where("key = ?", key).Limit(1).Find(&saved).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, false, err
+	}
+	if len(saved) > 0 {
+		return &saved[0], false, nil
+	}
+
+	claimed := &IdempotencyKey{Key: key, Request: request}
+	err = database.DBConn.Create(claimed).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, false, err
+	}
+	return claimed, true, nil
+}
+
+// ReleaseIdempotencyKey forgets the claimed key so the request can be made again with it.
+func ReleaseIdempotencyKey(key *IdempotencyKey) error {
+	err := database.DBConn.Delete(&IdempotencyKey{}, "key = ?", key.Key).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// ForgetUnfinishedIdempotencyKeys forgets the keys whose request never confirmed a reservation
+// because the server stopped, their reservations are compensated like the other pending ones.
+// The keys of the confirmed reservations are kept, they were saved with their responses along
+// with the confirmation.
+func ForgetUnfinishedIdempotencyKeys() error {
+	err := database.DBConn.Where("reservation_id = 0 OR json_response = ''").Delete(&IdempotencyKey{}).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
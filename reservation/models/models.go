@@ -1,14 +1,25 @@
 package models
 
 import (
-	"log"
+	"fmt"
 
 	"github.com/yusufatalay/SocketProgramming/reservation/database"
 )
 
-func init() {
-	err := database.DBConn.AutoMigrate(&RoomReservation{})
+// Migrate brings the tables of the connected database up to date with the models.
+func Migrate() error {
+	err := database.DBConn.AutoMigrate(&RoomReservation{}, &ReservationSeries{}, &IdempotencyKey{})
 	if err != nil {
-		log.Fatalf("Cannot migrate models: %s", err.Error())
+		return fmt.Errorf("cannot migrate models: %w", err)
 	}
+
+	// reservations made before minutes were introduced have their time in whole hours
+	if database.DBConn.Migrator().HasColumn(&RoomReservation{}, "hour") {
+		err = database.DBConn.Exec("UPDATE room_reservations SET starts_at = hour * 60, ends_at = (hour + duration) * 60 " +
+			"WHERE ends_at = 0 AND hour IS NOT NULL AND duration IS NOT NULL").Error
+		if err != nil {
+			return fmt.Errorf("cannot migrate reservations to minutes: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPageSize and MaxPageSize bound how many reservations a page of a listing holds.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// sortColumns are the orders the reservations can be listed in, each ends with the id so
+// that every reservation has its own place in it.
+var sortColumns = map[string][]string{
+	"date":     {"date", "starts_at", "id"},
+	"start":    {"starts_at", "date", "id"},
+	"room":     {"room_name", "date", "starts_at", "id"},
+	"activity": {"activity_name", "date", "starts_at", "id"},
+	"id":       {"id"},
+}
+
+// ReservationQuery selects the confirmed reservations to list. Every filter that is set must
+// match: the room, the activity, the day of the week, the dates from From to To, and the hour
+// the reservation takes place in. The reservations that recur every week match the dates their
+// day falls on. Sort is one of date, start, room, activity or id, descending with a leading
+// "-". Cursor is the NextCursor of the previous page.
+type ReservationQuery struct {
+	RoomName     string
+	ActivityName string
+	Day          int
+	From         string
+	To           string
+	Hour         *int
+	Sort         string
+	Cursor       string
+	Limit        int
+}
+
+// ReservationPage is a page of a listing, NextCursor is empty on the last page.
+type ReservationPage struct {
+	Reservations []RoomReservation `json:"reservations"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+}
+
+// reservationCursor is the place of the last reservation of a page in its order.
+type reservationCursor struct {
+	Sort         string `json:"sort"`
+	ID           uint   `json:"id"`
+	Date         string `json:"date"`
+	Start        Clock  `json:"start"`
+	RoomName     string `json:"room"`
+	ActivityName string `json:"activity"`
+}
+
+// Validate fills the defaults of the query and checks its filters, the error is meant to be
+// shown to the client.
+func (query *ReservationQuery) Validate() error {
+	if query.Sort == "" {
+		query.Sort = "date"
+	}
+	if _, ok := sortColumns[strings.TrimPrefix(query.Sort, "-")]; !ok {
+		return fmt.Errorf("sort should be one of date, start, room, activity or id, not %q", query.Sort)
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 1 || query.Limit > MaxPageSize {
+		return fmt.Errorf("limit should be between 1 and %d", MaxPageSize)
+	}
+
+	if query.Day != 0 && (query.Day < 1 || query.Day > 7) {
+		return errors.New("day should be between 1 (Monday) and 7 (Sunday)")
+	}
+	if query.Hour != nil && (*query.Hour < 0 || *query.Hour > 23) {
+		return errors.New("hour should be between 0 and 23")
+	}
+
+	// a single date is a range of one day
+	if query.From == "" {
+		query.From = query.To
+	}
+	if query.To == "" {
+		query.To = query.From
+	}
+	if query.From != "" {
+		from, err := time.Parse(DateLayout, query.From)
+		if err != nil {
+			return errors.New("dates should be in YYYY-MM-DD format")
+		}
+		to, err := time.Parse(DateLayout, query.To)
+		if err != nil {
+			return errors.New("dates should be in YYYY-MM-DD format")
+		}
+		if to.Before(from) {
+			return errors.New("from should not be after to")
+		}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort {
+			return errors.New("cursor is not one of this listing")
+		}
+	}
+	return nil
+}
+
+// FindReservations returns the page of the reservations the validated query selects.
+func FindReservations(query *ReservationQuery) (*ReservationPage, error) {
+	db := database.DBConn.Model(RoomReservation{}).Where("status = ?", StatusConfirmed)
+	if query.RoomName != "" {
+		db = db.Where("room_name = ?", query.RoomName)
+	}
+	if query.ActivityName != "" {
+		db = db.Where("activity_name = ?", query.ActivityName)
+	}
+	if query.Day != 0 {
+		db = db.Where("day = ?", query.Day)
+	}
+	if query.From != "" {
+		db = db.Scopes(takingPlaceBetween(query.From, query.To))
+	}
+	if query.Hour != nil {
+		db = db.Where("starts_at < ? AND ends_at > ?", ClockOf(*query.Hour+1, 0), ClockOf(*query.Hour, 0))
+	}
+
+	descending := strings.HasPrefix(query.Sort, "-")
+	columns := sortColumns[strings.TrimPrefix(query.Sort, "-")]
+	order := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if descending {
+			order = append(order, column+" DESC")
+		} else {
+			order = append(order, column)
+		}
+	}
+	db = db.Order(strings.Join(order, ", "))
+
+	// continue after the last reservation of the previous page
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		comparison := ">"
+		if descending {
+			comparison = "<"
+		}
+		db = db.Where(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), comparison,
+			strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")), cursor.values(columns)...)
+	}
+
+	// one more than the page tells if there is a next page
+	reservations := []RoomReservation{}
+	err := db.Limit(query.Limit + 1).Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+
+	page := &ReservationPage{Reservations: reservations}
+	if len(reservations) > query.Limit {
+		page.Reservations = reservations[:query.Limit]
+		last := page.Reservations[query.Limit-1]
+		page.NextCursor = encodeCursor(&reservationCursor{
+			Sort:         query.Sort,
+			ID:           last.ID,
+			Date:         last.Date,
+			Start:        last.Start,
+			RoomName:     last.RoomName,
+			ActivityName: last.ActivityName,
+		})
+	}
+	return page, nil
+}
+
+// takingPlaceBetween limits a query to the reservations that take place between the dates,
+// the ones without a date recur every week on their day.
+func takingPlaceBetween(from string, to string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		first, _ := time.Parse(DateLayout, from)
+		last, _ := time.Parse(DateLayout, to)
+		days := []int{}
+		for date := first; !date.After(last) && len(days) < 7; date = date.AddDate(0, 0, 1) {
+			// the days of the week are numbered from Monday (1) to Sunday (7)
+			days = append(days, (int(date.Weekday())+6)%7+1)
+		}
+		return db.Where("((date >= ? AND date <= ?) OR (date = '' AND day IN ?))", from, to, days)
+	}
+}
+
+// values are the values of the columns at the cursor.
+func (cursor *reservationCursor) values(columns []string) []interface{} {
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		switch column {
+		case "id":
+			values = append(values, cursor.ID)
+		case "date":
+			values = append(values, cursor.Date)
+		case "starts_at":
+			values = append(values, cursor.Start)
+		case "room_name":
+			values = append(values, cursor.RoomName)
+		case "activity_name":
+			values = append(values, cursor.ActivityName)
+		}
+	}
+	return values
+}
+
+func encodeCursor(cursor *reservationCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (*reservationCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &reservationCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
@@ -2,20 +2,41 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"log"
 
 	"github.com/yusufatalay/SocketProgramming/reservation/database"
+
+	"gorm.io/gorm"
 )
 
+// RoomReservation with a Date is made for that date only, without one it recurs every week on its Day.
+// Its time is from Start to End, older clients may give it in whole hours with Hour and Duration instead.
+// RoomBookingID is the id of the reservation on the room server, it is zero for the reservations
+// made before it was kept. A reservation is pending while its room is being booked, the booking
+// carries the reservation's Reference so it can be released even if its id never arrives.
 type RoomReservation struct {
-	ID           uint   `gorm:"primaryKey" json:"id"`
-	ActivityName string `json:"activity_name"`
-	RoomName     string `json:"room_name"`
-	Day          int    `json:"day"`
-	Hour         int    `json:"hour"`
-	Duration     int    `json:"duration"`
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	ActivityName  string `json:"activity_name"`
+	RoomName      string `json:"room_name"`
+	Date          string `gorm:"not null;default:''" json:"date,omitempty"`
+	Day           int    `json:"day"`
+	Start         Clock  `gorm:"column:starts_at;not null;default:0" json:"start"`
+	End           Clock  `gorm:"column:ends_at;not null;default:0" json:"end"`
+	Hour          int    `gorm:"-" json:"hour,omitempty"`
+	Duration      int    `gorm:"-" json:"duration,omitempty"`
+	SeriesID      uint   `gorm:"not null;default:0" json:"series_id,omitempty"`
+	RoomBookingID uint   `gorm:"not null;default:0" json:"-"`
+	Status        string `gorm:"not null;default:'confirmed'" json:"status"`
+	Reference     string `gorm:"not null;default:''" json:"-"`
 }
 
+// Statuses of the reservations.
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+)
+
 func CreateRoomReservation(roomreservation *RoomReservation) (uint, error) {
 	err := database.DBConn.Create(&roomreservation).Error
 	if err != nil {
@@ -28,6 +49,41 @@ func CreateRoomReservation(roomreservation *RoomReservation) (uint, error) {
 
 }
 
+// CreatePendingReservations saves all of the reservations as pending or none of them, and gives
+// each of them the reference its room booking is made with, which is made of its ID. The ID of a
+// removed reservation may be given again, but a reservation is only removed once its booking is
+// released, so no two bookings the server knows of share a reference.
+func CreatePendingReservations(reservations []RoomReservation) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		return createPending(tx, reservations)
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// createPending saves the reservations as pending and gives them their references.
+func createPending(tx *gorm.DB, reservations []RoomReservation) error {
+	for i := range reservations {
+		reservations[i].Status = StatusPending
+	}
+	err := tx.Create(&reservations).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range reservations {
+		reservations[i].Reference = fmt.Sprintf("reservation/%d", reservations[i].ID)
+		err = tx.Model(&reservations[i]).Update("reference", reservations[i].Reference).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func GetReservationByID(id uint) (*RoomReservation, error) {
 	var exists bool
 	err := database.DBConn.Model(RoomReservation{}).Select("count(*) > 0").Where("id = ?", id).Find(&exists).Error
@@ -49,3 +105,125 @@ func GetReservationByID(id uint) (*RoomReservation, error) {
 
 	return reservation, nil
 }
+
+// ConfirmReservation saves the pending reservation as booked on the room server. The claimed
+// idempotency key of the request, if there is one, is saved with the reservation's ID and its
+// responses in the same transaction, so a confirmed reservation always has its key and the
+// retries get the responses.
+func ConfirmReservation(reservation *RoomReservation, key *IdempotencyKey) error {
+	reservation.Status = StatusConfirmed
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(reservation).Error
+		if err != nil || key == nil {
+			return err
+		}
+		key.ReservationID = reservation.ID
+		return tx.Save(key).Error
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// ConfirmReservations saves all of the pending reservations as booked on the room server,
+// or none of them.
+func ConfirmReservations(reservations []RoomReservation) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		return confirm(tx, reservations)
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// confirm saves the pending reservations as booked.
+func confirm(tx *gorm.DB, reservations []RoomReservation) error {
+	for i := range reservations {
+		reservations[i].Status = StatusConfirmed
+		err := tx.Save(&reservations[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAllReservations returns every reservation, the pending ones included.
+func GetAllReservations() ([]RoomReservation, error) {
+	reservations := []RoomReservation{}
+	err := database.DBConn.Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// GetPendingReservations returns the reservations whose room may or may not have been booked.
+func GetPendingReservations() ([]RoomReservation, error) {
+	reservations := []RoomReservation{}
+	err := database.DBConn.Where("status = ?", StatusPending).Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// UpdateReservation saves the changes of the reservation, it keeps its ID.
+func UpdateReservation(reservation *RoomReservation) error {
+	err := database.DBConn.Save(reservation).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveReservation permanently removes the reservation, and its series too if it was the
+// last occurrence of one.
+func RemoveReservation(reservation *RoomReservation) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&RoomReservation{}, reservation.ID).Error
+		if err != nil || reservation.SeriesID == 0 {
+			return err
+		}
+
+		var left int64
+		err = tx.Model(RoomReservation{}).Where("series_id = ?", reservation.SeriesID).Count(&left).Error
+		if err != nil || left > 0 {
+			return err
+		}
+		return tx.Delete(&ReservationSeries{}, reservation.SeriesID).Error
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// RenameActivity moves the reservations and the series of the activity to its new name, and
+// returns how many reservations were moved. Renaming an activity with no reservations left
+// changes nothing, so a rename can be repeated.
+func RenameActivity(name string, newname string) (int64, error) {
+	var renamed int64
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(RoomReservation{}).Where("activity_name = ?", name).Update("activity_name", newname)
+		if result.Error != nil {
+			return result.Error
+		}
+		renamed = result.RowsAffected
+
+		return tx.Model(ReservationSeries{}).Where("activity_name = ?", name).Update("activity_name", newname).Error
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return 0, err
+	}
+	return renamed, nil
+}
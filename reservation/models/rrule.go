@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is the ISO 8601 calendar date format reservations use.
+const DateLayout = "2006-01-02"
+
+// MaxOccurrences bounds the number of reservations a recurrence rule can expand to.
+const MaxOccurrences = 100
+
+// maxRecurrenceYears bounds how far the occurrences of a rule are looked for.
+const maxRecurrenceYears = 2
+
+// RRule is the subset of the RFC 5545 recurrence rules that reservations can repeat by,
+// such as FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=10. Weeks start on Monday.
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	// Until is the last date an occurrence can be on, zero when the rule has a Count
+	Until time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRRule parses a rule with the FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL, BYDAY,
+// COUNT and UNTIL parts, either COUNT or UNTIL should be given.
+func ParseRRule(rule string) (*RRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	rrule := &RRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("malformed rrule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rrule.Freq = strings.ToUpper(value)
+			if rrule.Freq != "DAILY" && rrule.Freq != "WEEKLY" && rrule.Freq != "MONTHLY" {
+				return nil, fmt.Errorf("unsupported rrule frequency %q", value)
+			}
+		case "INTERVAL":
+			rrule.Interval, err = strconv.Atoi(value)
+			if err != nil || rrule.Interval < 1 {
+				return nil, errors.New("rrule INTERVAL should be a positive number")
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(value), ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported rrule day %q", day)
+				}
+				rrule.ByDay = append(rrule.ByDay, weekday)
+			}
+		case "COUNT":
+			rrule.Count, err = strconv.Atoi(value)
+			if err != nil || rrule.Count < 1 {
+				return nil, errors.New("rrule COUNT should be a positive number")
+			}
+		case "UNTIL":
+			rrule.Until, err = parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", name)
+		}
+	}
+
+	if rrule.Freq == "" {
+		return nil, errors.New("rrule FREQ is missing")
+	}
+	if rrule.Count == 0 && rrule.Until.IsZero() {
+		return nil, errors.New("rrule should have either COUNT or UNTIL")
+	}
+	if rrule.Count != 0 && !rrule.Until.IsZero() {
+		return nil, errors.New("rrule cannot have both COUNT and UNTIL")
+	}
+	if rrule.Freq == "MONTHLY" && len(rrule.ByDay) > 0 {
+		return nil, errors.New("rrule BYDAY is not supported for the MONTHLY frequency")
+	}
+	return rrule, nil
+}
+
+// parseUntil parses the date of an UNTIL part, the time of a date-time is ignored.
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z", "20060102T150405", DateLayout} {
+		until, err := time.Parse(layout, value)
+		if err == nil {
+			return time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("malformed rrule UNTIL %q", value)
+}
+
+// Dates expands the rule from its first date, which is only an occurrence if it matches the rule.
+func (rrule *RRule) Dates(first time.Time) ([]time.Time, error) {
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := first.AddDate(maxRecurrenceYears, 0, 0)
+
+	dates := []time.Time{}
+	// add appends the date and reports whether the expansion is over
+	add := func(date time.Time) (bool, error) {
+		if date.After(horizon) || (!rrule.Until.IsZero() && date.After(rrule.Until)) {
+			return true, nil
+		}
+		if len(dates) == MaxOccurrences {
+			return true, fmt.Errorf("rrule expands to more than %d occurrences", MaxOccurrences)
+		}
+		dates = append(dates, date)
+		return rrule.Count != 0 && len(dates) == rrule.Count, nil
+	}
+
+	switch rrule.Freq {
+	case "DAILY":
+		for date := first; ; date = date.AddDate(0, 0, rrule.Interval) {
+			if date.After(horizon) {
+				return dates, nil
+			}
+			if len(rrule.ByDay) > 0 && !containsWeekday(rrule.ByDay, date.Weekday()) {
+				continue
+			}
+			done, err := add(date)
+			if err != nil || done {
+				return dates, err
+			}
+		}
+	case "WEEKLY":
+		byday := rrule.ByDay
+		if len(byday) == 0 {
+			byday = []time.Weekday{first.Weekday()}
+		}
+		// the days of a week in order, starting from Monday
+		offsets := []int{}
+		for _, day := range byday {
+			offsets = append(offsets, (int(day)+6)%7)
+		}
+		sort.Ints(offsets)
+
+		monday := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+		for week := monday; ; week = week.AddDate(0, 0, 7*rrule.Interval) {
+			if week.After(horizon) {
+				return dates, nil
+			}
+			for _, offset := range offsets {
+				date := week.AddDate(0, 0, offset)
+				if date.Before(first) {
+					continue
+				}
+				done, err := add(date)
+				if err != nil || done {
+					return dates, err
+				}
+			}
+		}
+	default:
+		for month := 0; ; month += rrule.Interval {
+			date := time.Date(first.Year(), first.Month()+time.Month(month), first.Day(), 0, 0, 0, 0, time.UTC)
+			if date.After(horizon) {
+				return dates, nil
+			}
+			// months without the day of the first date are skipped
+			if date.Day() != first.Day() {
+				continue
+			}
+			done, err := add(date)
+			if err != nil || done {
+				return dates, err
+			}
+		}
+	}
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// day is the date at midnight in UTC.
+func day(year int, month time.Month, date int) time.Time {
+	return time.Date(year, month, date, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    *RRule
+		wantErr bool
+	}{
+		{"count", "FREQ=DAILY;COUNT=3", &RRule{Freq: "DAILY", Interval: 1, Count: 3}, false},
+		{"prefix and lower case", "RRULE:freq=weekly;byday=tu,th;count=2",
+			&RRule{Freq: "WEEKLY", Interval: 1, ByDay: []time.Weekday{time.Tuesday, time.Thursday}, Count: 2}, false},
+		{"interval", "FREQ=WEEKLY;INTERVAL=2;COUNT=5", &RRule{Freq: "WEEKLY", Interval: 2, Count: 5}, false},
+		{"until date", "FREQ=MONTHLY;UNTIL=20300131", &RRule{Freq: "MONTHLY", Interval: 1, Until: day(2030, 1, 31)}, false},
+		{"until date-time drops the time", "FREQ=DAILY;UNTIL=20300131T235959Z",
+			&RRule{Freq: "DAILY", Interval: 1, Until: day(2030, 1, 31)}, false},
+		{"until ISO date", "FREQ=DAILY;UNTIL=2030-01-31", &RRule{Freq: "DAILY", Interval: 1, Until: day(2030, 1, 31)}, false},
+		{"empty", "", nil, true},
+		{"missing freq", "COUNT=3", nil, true},
+		{"unsupported freq", "FREQ=YEARLY;COUNT=3", nil, true},
+		{"neither count nor until", "FREQ=DAILY", nil, true},
+		{"both count and until", "FREQ=DAILY;COUNT=3;UNTIL=20300131", nil, true},
+		{"zero count", "FREQ=DAILY;COUNT=0", nil, true},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0;COUNT=3", nil, true},
+		{"unknown day", "FREQ=WEEKLY;BYDAY=XX;COUNT=3", nil, true},
+		{"byday on monthly", "FREQ=MONTHLY;BYDAY=MO;COUNT=3", nil, true},
+		{"part without value", "FREQ=DAILY;COUNT", nil, true},
+		{"unsupported part", "FREQ=DAILY;COUNT=3;WKST=MO", nil, true},
+		{"malformed until", "FREQ=DAILY;UNTIL=tomorrow", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRRule(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRRule(%q) = %+v, want %+v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleDates(t *testing.T) {
+	// 2030-01-07 is a Monday
+	monday := day(2030, 1, 7)
+	tests := []struct {
+		name  string
+		rule  string
+		first time.Time
+		// want is the dates, or only their number and the last one when it is nil
+		want    []time.Time
+		count   int
+		last    time.Time
+		wantErr bool
+	}{
+		{"daily", "FREQ=DAILY;COUNT=3", monday, []time.Time{day(2030, 1, 7), day(2030, 1, 8), day(2030, 1, 9)}, 0, time.Time{}, false},
+		{"daily interval", "FREQ=DAILY;INTERVAL=2;COUNT=3", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 9), day(2030, 1, 11)}, 0, time.Time{}, false},
+		{"daily byday skips the other days", "FREQ=DAILY;BYDAY=SA,SU;COUNT=3", monday,
+			[]time.Time{day(2030, 1, 12), day(2030, 1, 13), day(2030, 1, 19)}, 0, time.Time{}, false},
+		{"weekly on the first date's day", "FREQ=WEEKLY;COUNT=3", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 14), day(2030, 1, 21)}, 0, time.Time{}, false},
+		{"weekly interval", "FREQ=WEEKLY;INTERVAL=2;COUNT=3", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 21), day(2030, 2, 4)}, 0, time.Time{}, false},
+		{"weekly byday in week order", "FREQ=WEEKLY;BYDAY=TH,TU;COUNT=4", monday,
+			[]time.Time{day(2030, 1, 8), day(2030, 1, 10), day(2030, 1, 15), day(2030, 1, 17)}, 0, time.Time{}, false},
+		{"weekly byday before the first date is skipped", "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3", day(2030, 1, 9),
+			[]time.Time{day(2030, 1, 11), day(2030, 1, 14), day(2030, 1, 18)}, 0, time.Time{}, false},
+		{"until is inclusive", "FREQ=WEEKLY;UNTIL=20300121", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 14), day(2030, 1, 21)}, 0, time.Time{}, false},
+		{"until stops before count would", "FREQ=DAILY;UNTIL=20300108", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 8)}, 0, time.Time{}, false},
+		{"count stops before until would", "FREQ=DAILY;COUNT=2", monday,
+			[]time.Time{day(2030, 1, 7), day(2030, 1, 8)}, 0, time.Time{}, false},
+		{"until before the first date", "FREQ=DAILY;UNTIL=20300101", monday, []time.Time{}, 0, time.Time{}, false},
+		{"monthly", "FREQ=MONTHLY;INTERVAL=2;COUNT=3", day(2030, 1, 15),
+			[]time.Time{day(2030, 1, 15), day(2030, 3, 15), day(2030, 5, 15)}, 0, time.Time{}, false},
+		{"monthly on the 29th skips February", "FREQ=MONTHLY;COUNT=3", day(2030, 1, 29),
+			[]time.Time{day(2030, 1, 29), day(2030, 3, 29), day(2030, 4, 29)}, 0, time.Time{}, false},
+		{"monthly on the 29th keeps a leap February", "FREQ=MONTHLY;COUNT=2", day(2032, 1, 29),
+			[]time.Time{day(2032, 1, 29), day(2032, 2, 29)}, 0, time.Time{}, false},
+		{"monthly on the 30th", "FREQ=MONTHLY;COUNT=2", day(2030, 1, 30),
+			[]time.Time{day(2030, 1, 30), day(2030, 3, 30)}, 0, time.Time{}, false},
+		{"monthly on the 31st skips the short months", "FREQ=MONTHLY;COUNT=4", day(2030, 1, 31),
+			[]time.Time{day(2030, 1, 31), day(2030, 3, 31), day(2030, 5, 31), day(2030, 7, 31)}, 0, time.Time{}, false},
+		{"as many as the cap", "FREQ=DAILY;COUNT=100", monday, nil, MaxOccurrences, day(2030, 4, 16), false},
+		{"more than the cap", "FREQ=DAILY;COUNT=101", monday, nil, 0, time.Time{}, true},
+		{"until past the cap", "FREQ=DAILY;UNTIL=20310101", monday, nil, 0, time.Time{}, true},
+		{"two year horizon", "FREQ=MONTHLY;UNTIL=20400101", monday, nil, 25, day(2032, 1, 7), false},
+		{"two year horizon with interval", "FREQ=WEEKLY;INTERVAL=4;UNTIL=20400101", monday, nil, 27, day(2032, 1, 5), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rrule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			got, err := rrule.Dates(tt.first)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dates(%s) of %q error = %v, want error %v", tt.first.Format(DateLayout), tt.rule, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want != nil {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Dates(%s) of %q = %v, want %v", tt.first.Format(DateLayout), tt.rule, got, tt.want)
+				}
+				return
+			}
+			if len(got) != tt.count || !got[len(got)-1].Equal(tt.last) {
+				t.Errorf("Dates(%s) of %q = %d dates up to %v, want %d up to %v", tt.first.Format(DateLayout), tt.rule,
+					len(got), got[len(got)-1], tt.count, tt.last)
+			}
+		})
+	}
+}
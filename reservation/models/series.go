@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"log"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+
+	"gorm.io/gorm"
+)
+
+// ReservationSeries is a reservation that repeats by its recurrence rule from its Date on,
+// each of its occurrences is a RoomReservation with the series' ID as their SeriesID.
+type ReservationSeries struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ActivityName string            `json:"activity_name"`
+	RoomName     string            `json:"room_name"`
+	Date         string            `json:"date"`
+	Start        Clock             `gorm:"column:starts_at" json:"start"`
+	End          Clock             `gorm:"column:ends_at" json:"end"`
+	RRule        string            `json:"rrule"`
+	Occurrences  []RoomReservation `gorm:"foreignKey:SeriesID" json:"occurrences,omitempty"`
+}
+
+// CreatePendingSeries saves the series along with its occurrences as pending, which get their
+// references like the ones of CreatePendingReservations.
+func CreatePendingSeries(series *ReservationSeries) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit("Occurrences").Create(series).Error
+		if err != nil {
+			return err
+		}
+		for i := range series.Occurrences {
+			series.Occurrences[i].SeriesID = series.ID
+		}
+		return createPending(tx, series.Occurrences)
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// ConfirmSeries saves the pending occurrences of the series as booked, along with the time of
+// the series, or none of them.
+func ConfirmSeries(series *ReservationSeries) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(series).Select("starts_at", "ends_at").Updates(series).Error
+		if err != nil {
+			return err
+		}
+		return confirm(tx, series.Occurrences)
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// GetSeriesByID returns the series with its occurrences ordered by date.
+func GetSeriesByID(id uint) (*ReservationSeries, error) {
+	series := &ReservationSeries{}
+	err := database.DBConn.Preload("Occurrences", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date")
+	}).Where("id = ?", id).Limit(1).Find(series).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if series.ID == 0 {
+		return nil, errors.New("ReservationSeries does not exists")
+	}
+	return series, nil
+}
+
+// RemoveOccurrences deletes the given occurrences of the series, and the series itself once
+// it has no occurrences left.
+func RemoveOccurrences(series *ReservationSeries, occurrences []RoomReservation) error {
+	ids := make([]uint, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		ids = append(ids, occurrence.ID)
+	}
+
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("series_id = ? AND id IN ?", series.ID, ids).Delete(&RoomReservation{}).Error
+		if err != nil {
+			return err
+		}
+
+		var left int64
+		err = tx.Model(RoomReservation{}).Where("series_id = ?", series.ID).Count(&left).Error
+		if err != nil || left > 0 {
+			return err
+		}
+		return tx.Delete(&ReservationSeries{}, series.ID).Error
+	})
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// reconcileLock is held for reading by the handlers while they change bookings on the room
+// server, and for writing while the reservations are reconciled, so a reconciliation never
+// sees one side of a change without the other.
+var reconcileLock sync.RWMutex
+
+// reconcileReport lists the differences between the reservations and the room server's bookings.
+// OrphanedBookings are room bookings no reservation has, DanglingReservations are confirmed
+// reservations whose booking is gone. With Repaired the orphaned bookings were released and the
+// dangling reservations removed, a dry run only reports them.
+type reconcileReport struct {
+	DryRun               bool                     `json:"dry_run"`
+	Reservations         int                      `json:"reservations"`
+	Bookings             int                      `json:"bookings"`
+	OrphanedBookings     []client.Reservation     `json:"orphaned_bookings"`
+	DanglingReservations []models.RoomReservation `json:"dangling_reservations"`
+	Repaired             bool                     `json:"repaired"`
+}
+
+// HandleReconcile compares the reservations with the bookings of the room server and reports the
+// ones without a counterpart. It is a dry run unless repair is true.
+func HandleReconcile(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Repair bool `json:"repair"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		if req.Query.Get("repair") != "" {
+			repair, err := strconv.ParseBool(req.Query.Get("repair"))
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+					"Parser Error", "repair should be true or false")
+
+				return
+			}
+			body.Repair = repair
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
+
+				return
+			}
+		}
+	}
+
+	response = bookings.reconcile(req, body.Repair)
+}
+
+// reconcile compares the reservations with the bookings of the room server. With repair it
+// releases the orphaned bookings first and removes the dangling reservations after, so nothing
+// is removed locally if the room server cannot release them.
+func (b *booker) reconcile(req *helper.Request, repair bool) string {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	reservations, err := models.GetAllReservations()
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+	bookings, err := b.rooms.Reservations(ctx)
+	if err != nil {
+		return upstreamError(req, err)
+	}
+
+	report := compareReservations(reservations, bookings)
+	report.DryRun = !repair
+	if repair {
+		ids := make([]uint, 0, len(report.OrphanedBookings))
+		for _, booking := range report.OrphanedBookings {
+			ids = append(ids, booking.ID)
+		}
+		if len(ids) > 0 {
+			_, err = b.rooms.Release(ctx, ids)
+			if err != nil {
+				return upstreamError(req, err)
+			}
+		}
+
+		for i := range report.DanglingReservations {
+			err = models.RemoveReservation(&report.DanglingReservations[i])
+			if err != nil {
+				return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+					"Database error.", err.Error())
+			}
+		}
+		report.Repaired = true
+	}
+
+	return helper.CreateSuccessResponse(req, "Reservation", "Reconciliation report.", reconcileDetails(report), report)
+}
+
+// compareReservations pairs every reservation with its booking. A reservation's booking is the one
+// with its RoomBookingID when that is at the reservation's time, otherwise the one with its
+// Reference, and for the reservations that have neither, an unpaired one at the same time in the
+// same room. The bookings of the pending reservations are
+// paired by their reference but a pending reservation is never dangling, its saga settles it.
+func compareReservations(reservations []models.RoomReservation, bookings []client.Reservation) *reconcileReport {
+	report := &reconcileReport{
+		Reservations:         len(reservations),
+		Bookings:             len(bookings),
+		OrphanedBookings:     []client.Reservation{},
+		DanglingReservations: []models.RoomReservation{},
+	}
+
+	byID := make(map[uint]int, len(bookings))
+	byReference := make(map[string]int, len(bookings))
+	for i, booking := range bookings {
+		byID[booking.ID] = i
+		if booking.Reference != "" {
+			byReference[booking.Reference] = i
+		}
+	}
+	paired := make([]bool, len(bookings))
+
+	unknown := []models.RoomReservation{}
+	for _, res := range reservations {
+		i, found := -1, false
+		if res.RoomBookingID != 0 {
+			i, found = byID[res.RoomBookingID]
+			// the room server may have given the id of a removed booking to another one
+			found = found && sameBooking(&res, &bookings[i])
+		}
+		if !found && res.Reference != "" {
+			// the id never arrived, or it is outdated
+			i, found = byReference[res.Reference]
+		}
+		if res.RoomBookingID == 0 && res.Reference == "" && res.Status == models.StatusConfirmed {
+			unknown = append(unknown, res)
+			continue
+		}
+
+		if found {
+			paired[i] = true
+		} else if res.Status == models.StatusConfirmed {
+			report.DanglingReservations = append(report.DanglingReservations, res)
+		}
+	}
+
+	// the reservations made before the room server's ids were kept take what is left
+	for _, res := range unknown {
+		found := false
+		for i := range bookings {
+			if !paired[i] && sameBooking(&res, &bookings[i]) {
+				paired[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			report.DanglingReservations = append(report.DanglingReservations, res)
+		}
+	}
+
+	for i, booking := range bookings {
+		if !paired[i] {
+			report.OrphanedBookings = append(report.OrphanedBookings, booking)
+		}
+	}
+	return report
+}
+
+// sameBooking reports whether the booking is in the room of the reservation at its time.
+func sameBooking(res *models.RoomReservation, booking *client.Reservation) bool {
+	return res.RoomName == booking.RoomName && res.Date == booking.Date && res.Day == booking.Day &&
+		res.Start == booking.Start && res.End == booking.End
+}
+
+// reconcileDetails is the human readable reconciliation report for the HTML pages.
+func reconcileDetails(report *reconcileReport) string {
+	details := strings.Builder{}
+	if report.DryRun {
+		details.WriteString("Dry run, nothing is changed.\r\n")
+	}
+	details.WriteString(fmt.Sprintf("Compared %d reservations with %d room bookings.\r\n\r\n", report.Reservations, report.Bookings))
+
+	details.WriteString("Orphaned room bookings:\r\n")
+	if len(report.OrphanedBookings) == 0 {
+		details.WriteString("None\r\n")
+	}
+	for _, booking := range report.OrphanedBookings {
+		details.WriteString(fmt.Sprintf("Booking ID %d of %s, %s %s-%s\r\n", booking.ID, booking.RoomName,
+			dateOrEveryWeek(booking.Date, booking.Day), booking.Start, booking.End))
+	}
+	details.WriteString("\r\nDangling reservations:\r\n")
+	if len(report.DanglingReservations) == 0 {
+		details.WriteString("None\r\n")
+	}
+	for i := range report.DanglingReservations {
+		res := &report.DanglingReservations[i]
+		details.WriteString(fmt.Sprintf("Reservation ID %d of %s, %s %s-%s\r\n", res.ID, res.RoomName,
+			dateOrEveryWeek(res.Date, res.Day), res.Start, res.End))
+	}
+
+	if report.Repaired {
+		details.WriteString("\r\nThe orphaned bookings are released and the dangling reservations are removed.\r\n")
+	}
+	details.WriteString("\r\n")
+	return details.String()
+}
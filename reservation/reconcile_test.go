@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// booking is a booking of A101 on Monday from the hour, for an hour.
+func booking(id uint, reference string, hour int) client.Reservation {
+	return client.Reservation{ID: id, RoomName: "A101", Day: 1, Start: models.ClockOf(hour, 0), End: models.ClockOf(hour+1, 0),
+		Reference: reference}
+}
+
+// reservation is a reservation of A101 on Monday from the hour, for an hour.
+func reservation(id uint, bookingID uint, reference string, status string, hour int) models.RoomReservation {
+	return models.RoomReservation{ID: id, RoomName: "A101", ActivityName: "Lecture", Day: 1, Start: models.ClockOf(hour, 0),
+		End: models.ClockOf(hour+1, 0), RoomBookingID: bookingID, Reference: reference, Status: status}
+}
+
+func TestCompareReservations(t *testing.T) {
+	confirmed, pending := models.StatusConfirmed, models.StatusPending
+	tests := []struct {
+		name         string
+		reservations []models.RoomReservation
+		bookings     []client.Reservation
+		// wantOrphans are the ids of the orphaned bookings, wantDangling the ids of the dangling reservations
+		wantOrphans  []uint
+		wantDangling []uint
+	}{
+		{"paired by id",
+			[]models.RoomReservation{reservation(1, 5, "", confirmed, 10)},
+			[]client.Reservation{booking(5, "", 10)}, nil, nil},
+		{"id given to a booking at another time",
+			[]models.RoomReservation{reservation(1, 5, "", confirmed, 10)},
+			[]client.Reservation{booking(5, "", 12)}, []uint{5}, []uint{1}},
+		{"outdated id falls back to the reference",
+			[]models.RoomReservation{reservation(1, 5, "reservation/1", confirmed, 10)},
+			[]client.Reservation{booking(5, "reservation/9", 12), booking(7, "reservation/1", 10)}, []uint{5}, nil},
+		{"id of no booking falls back to the reference",
+			[]models.RoomReservation{reservation(1, 5, "reservation/1", confirmed, 10)},
+			[]client.Reservation{booking(7, "reservation/1", 10)}, nil, nil},
+		{"paired by reference without an id",
+			[]models.RoomReservation{reservation(1, 0, "reservation/1", confirmed, 10)},
+			[]client.Reservation{booking(7, "reservation/1", 10)}, nil, nil},
+		{"reference of no booking",
+			[]models.RoomReservation{reservation(1, 0, "reservation/1", confirmed, 10)},
+			[]client.Reservation{booking(7, "reservation/2", 10)}, []uint{7}, []uint{1}},
+		{"reservation without id or reference paired by time",
+			[]models.RoomReservation{reservation(1, 0, "", confirmed, 10)},
+			[]client.Reservation{booking(8, "", 10)}, nil, nil},
+		{"reservation without id or reference takes no paired booking",
+			[]models.RoomReservation{reservation(1, 0, "", confirmed, 10), reservation(2, 8, "", confirmed, 10)},
+			[]client.Reservation{booking(8, "", 10)}, nil, []uint{1}},
+		{"reservation without id or reference at another time",
+			[]models.RoomReservation{reservation(1, 0, "", confirmed, 10)},
+			[]client.Reservation{booking(8, "", 12)}, []uint{8}, []uint{1}},
+		{"pending reservation is never dangling",
+			[]models.RoomReservation{reservation(1, 0, "reservation/1", pending, 10), reservation(2, 0, "", pending, 10)},
+			nil, nil, nil},
+		{"pending reservation pairs its booking",
+			[]models.RoomReservation{reservation(1, 0, "reservation/1", pending, 10)},
+			[]client.Reservation{booking(7, "reservation/1", 10)}, nil, nil},
+		{"booking of no reservation",
+			nil,
+			[]client.Reservation{booking(7, "reservation/1", 10), booking(8, "", 12)}, []uint{7, 8}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := compareReservations(tt.reservations, tt.bookings)
+			if report.Reservations != len(tt.reservations) || report.Bookings != len(tt.bookings) {
+				t.Errorf("got %d reservations and %d bookings, want %d and %d", report.Reservations, report.Bookings,
+					len(tt.reservations), len(tt.bookings))
+			}
+
+			orphans := []uint{}
+			for _, orphan := range report.OrphanedBookings {
+				orphans = append(orphans, orphan.ID)
+			}
+			dangling := []uint{}
+			for _, res := range report.DanglingReservations {
+				dangling = append(dangling, res.ID)
+			}
+			if fmt.Sprint(orphans) != fmt.Sprint(tt.wantOrphans) {
+				t.Errorf("got orphaned bookings %v, want %v", orphans, tt.wantOrphans)
+			}
+			if fmt.Sprint(dangling) != fmt.Sprint(tt.wantDangling) {
+				t.Errorf("got dangling reservations %v, want %v", dangling, tt.wantDangling)
+			}
+		})
+	}
+}
+
+// unreconciled saves a booked reservation, a dangling one and books an orphan, and returns the
+// booked reservation.
+func unreconciled(t *testing.T, b *booker, rooms *fakeRooms) *models.RoomReservation {
+	t.Helper()
+
+	booked, _ := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if booked == nil {
+		t.Fatal("reserve failed")
+	}
+	dangling := reservation(0, 99, "reservation/99", models.StatusConfirmed, 14)
+	_, err := models.CreateRoomReservation(&dangling)
+	if err != nil {
+		t.Fatalf("CreateRoomReservation: %v", err)
+	}
+	rooms.bookings["orphan"] = booking(98, "", 16)
+	return booked
+}
+
+func TestReconcileDryRunChangesNothing(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+	unreconciled(t, b, rooms)
+
+	response := b.reconcile(jsonRequest(t), false)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 2 {
+		t.Errorf("got bookings %+v, want both kept", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 2 {
+		t.Errorf("got reservations %+v, want both kept", saved)
+	}
+}
+
+func TestReconcileRepairReleasesOrphansAndRemovesDangling(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+	booked := unreconciled(t, b, rooms)
+
+	response := b.reconcile(jsonRequest(t), true)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	if bookings := rooms.booked(); len(bookings) != 1 || bookings[booked.Reference].ID != booked.RoomBookingID {
+		t.Errorf("got bookings %+v, want only the reservation's", bookings)
+	}
+	if saved := reservationsIn(t); len(saved) != 1 || saved[0].ID != booked.ID {
+		t.Errorf("got reservations %+v, want only the booked one", saved)
+	}
+}
+
+func TestReconcileRepairReleaseFailsRemovesNothing(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+	unreconciled(t, b, rooms)
+	rooms.releaseErrs = []error{context.DeadlineExceeded}
+
+	response := b.reconcile(jsonRequest(t), true)
+	if status := statusOf(t, response); status == 200 {
+		t.Fatalf("got status 200, want the release failure: %s", response)
+	}
+	if booked := rooms.booked(); len(booked) != 2 {
+		t.Errorf("got bookings %+v, want both kept", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 2 {
+		t.Errorf("got reservations %+v, want the dangling one kept until its orphans are released", saved)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// compensationInterval is how often the reservations left pending by the failed sagas are
+// compensated.
+const compensationInterval = time.Minute
+
+// sagaLock is held while a saga saves its pending reservation and while the pending
+// reservations are looked for, so a saga that just started is never taken for a failed one.
+var sagaLock sync.Mutex
+
+// sagas holds the ids of the pending reservations whose saga is still running.
+var sagas sync.Map
+
+// roomBooker books, moves and releases the rooms of the reservations, it is the room server's
+// client.
+type roomBooker interface {
+	Reserve(ctx context.Context, reservation client.Reservation) (*client.Reservation, *client.Alternatives, error)
+	ReserveBatch(ctx context.Context, reservations []client.Reservation) ([]client.Reservation, []client.Conflict, error)
+	Swap(ctx context.Context, old client.Reservation, replacement client.Reservation) (*client.Reservation, error)
+	Reservations(ctx context.Context) ([]client.Reservation, error)
+	Release(ctx context.Context, ids []uint) ([]uint, error)
+	ReleaseReferences(ctx context.Context, references ...string) ([]uint, error)
+	ReleaseMatching(ctx context.Context, reservations []client.Reservation) ([]uint, error)
+}
+
+// activityChecker tells whether an activity exists, it is the activity server's client.
+type activityChecker interface {
+	CheckActivity(ctx context.Context, name string) (*client.Activity, error)
+}
+
+// booker runs the sagas that book the rooms of the reservations, on the rooms and activities
+// it is given.
+type booker struct {
+	rooms      roomBooker
+	activities activityChecker
+}
+
+// bookings runs the sagas of the handlers on the room and activity servers.
+var bookings *booker
+
+// reserve makes a reservation as a saga of these steps:
+//
+//  1. the reservation is saved as pending, with a reference made of its ID
+//  2. the room is booked with the reservation's reference
+//  3. the reservation is confirmed
+//
+// When a step after the first one fails or times out, the room booking is released by the
+// reference and the pending reservation is removed. When that fails too, the reservation
+// stays pending and compensatePendingReservations tries again later. The reservation is
+// returned along with the response when the saga succeeds. The claimed idempotency key of the
+// request, or nil, is saved with the reservation and its responses when it is confirmed.
+func (b *booker) reserve(req *helper.Request, body *models.RoomReservation, key *models.IdempotencyKey) (*models.RoomReservation, string) {
+	// keep a reconciliation from taking the booking of a running saga for an orphaned one
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// check activity server if the activity exists
+	_, err := b.activities.CheckActivity(ctx, body.ActivityName)
+	if err != nil {
+		return nil, upstreamError(req, err)
+	}
+
+	pending := []models.RoomReservation{{
+		RoomName:     body.RoomName,
+		ActivityName: body.ActivityName,
+		Date:         body.Date,
+		Day:          body.Day,
+		Start:        body.Start,
+		End:          body.End,
+	}}
+	reservation := &pending[0]
+	sagaLock.Lock()
+	err = models.CreatePendingReservations(pending)
+	if err == nil {
+		sagas.Store(reservation.ID, true)
+	}
+	sagaLock.Unlock()
+	if err != nil {
+		return nil, helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+	defer sagas.Delete(reservation.ID)
+
+	// ask room server to book the time slice
+	booked, alternatives, err := b.rooms.Reserve(ctx, client.Reservation{
+		RoomName:  body.RoomName,
+		Date:      body.Date,
+		Day:       body.Day,
+		Start:     body.Start,
+		End:       body.End,
+		Hour:      body.Hour,
+		Duration:  body.Duration,
+		Reference: reservation.Reference,
+	})
+	if err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Status < 500 {
+			// the room server refused, there is no booking to release
+			removePending(reservation)
+		} else {
+			// the room may have been booked before the call failed
+			b.compensate(reservation)
+		}
+		if alternatives != nil && apiErr.Code == client.CodeRoomBlackedOut {
+			return nil, helper.CreateErrorResponseWithData(req, "Reservation", 403, "Forbidden", helper.ErrRoomBlackedOut,
+				"Room not available.", alternativeDetails("The room is blacked out at that time.", alternatives), alternatives)
+		}
+		if alternatives != nil {
+			return nil, helper.CreateErrorResponseWithData(req, "Reservation", 403, "Forbidden", helper.ErrRoomReserved,
+				"Room not available.", alternativeDetails("The room is already reserved at that time.", alternatives), alternatives)
+		}
+		return nil, upstreamError(req, err)
+	}
+
+	// the room server fills the day of a dated reservation and the time of one given in whole hours
+	reservation.Date = booked.Date
+	reservation.Day = booked.Day
+	reservation.Start = booked.Start
+	reservation.End = booked.End
+	reservation.RoomBookingID = booked.ID
+	reservation.Status = models.StatusConfirmed
+	if key != nil {
+		key.JSONResponse = reservedResponse(acceptsJSON, reservation)
+		key.HTMLResponse = reservedResponse(nil, reservation)
+	}
+	err = models.ConfirmReservation(reservation, key)
+	if err != nil {
+		b.compensate(reservation)
+		return nil, helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+
+	return reservation, reservedResponse(req, reservation)
+}
+
+// reservedResponse is the response to the request that made the reservation.
+func reservedResponse(req *helper.Request, reservation *models.RoomReservation) string {
+	return helper.CreateSuccessResponse(req, "Reservation",
+		"Reservation successful.", reservationDetails(reservation), reservation)
+}
+
+// compensate releases the room bookings of the reservations by their references and removes
+// the pending reservations.
+func (b *booker) compensate(reservations ...*models.RoomReservation) {
+	// the saga's own context may be the one that timed out
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	references := make([]string, 0, len(reservations))
+	for _, reservation := range reservations {
+		references = append(references, reservation.Reference)
+	}
+	_, err := b.rooms.ReleaseReferences(ctx, references...)
+	if err != nil {
+		for _, reservation := range reservations {
+			log.Printf("Error: cannot release the room of reservation %d, it stays pending: %+v", reservation.ID, err)
+		}
+		return
+	}
+	for _, reservation := range reservations {
+		removePending(reservation)
+	}
+}
+
+func removePending(reservation *models.RoomReservation) {
+	err := models.RemoveReservation(reservation)
+	if err != nil {
+		log.Printf("Error: cannot remove pending reservation %d: %+v", reservation.ID, err)
+	}
+}
+
+// compensatePendingReservations compensates the sagas that ended without confirming or
+// removing their reservation, such as the ones cut short by a crash, every compensationInterval.
+func (b *booker) compensatePendingReservations() {
+	for {
+		b.compensatePending()
+		time.Sleep(compensationInterval)
+	}
+}
+
+// compensatePending compensates the pending reservations whose saga is not running anymore.
+func (b *booker) compensatePending() {
+	// keep a reconciliation from seeing a booking released but its reservation still pending
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	sagaLock.Lock()
+	pending, err := models.GetPendingReservations()
+	sagaLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	for i := range pending {
+		if _, running := sagas.Load(pending[i].ID); !running {
+			b.compensate(&pending[i])
+		}
+	}
+}
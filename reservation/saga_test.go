@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+
+	"gorm.io/gorm/logger"
+)
+
+// useTestDatabase points the models at an empty database of their own for the test.
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+
+	err := database.Connect(filepath.Join(t.TempDir(), "reservation.db"))
+	if err != nil {
+		t.Fatalf("cannot open test database: %v", err)
+	}
+	database.DBConn.Logger = logger.Default.LogMode(logger.Silent)
+	err = models.Migrate()
+	if err != nil {
+		t.Fatalf("cannot migrate test database: %v", err)
+	}
+
+	db := database.DBConn
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// failOn makes the statements of the database matching the trigger's event fail, e.g.
+// "BEFORE INSERT ON room_reservations".
+func failOn(t *testing.T, event string) {
+	t.Helper()
+
+	err := database.DBConn.Exec(fmt.Sprintf("CREATE TRIGGER injected_failure %s BEGIN SELECT RAISE(ABORT, 'injected failure'); END",
+		event)).Error
+	if err != nil {
+		t.Fatalf("cannot inject failure: %v", err)
+	}
+}
+
+// fakeRooms is a room server keeping its bookings in memory. It books all of the reservations
+// of a batch or none of them when some overlap a booking. A booking is made before the
+// reserveErr is returned, like a room server whose answer is lost after it committed. The
+// releaseErrs are returned by the next releases in order, without releasing anything.
+type fakeRooms struct {
+	mu          sync.Mutex
+	nextID      uint
+	bookings    map[string]client.Reservation
+	reserveErr  error
+	releaseErrs []error
+	reserved    int
+}
+
+func newFakeRooms() *fakeRooms {
+	return &fakeRooms{bookings: map[string]client.Reservation{}}
+}
+
+func (f *fakeRooms) Reserve(ctx context.Context, reservation client.Reservation) (*client.Reservation, *client.Alternatives, error) {
+	booked, _, err := f.ReserveBatch(ctx, []client.Reservation{reservation})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &booked[0], nil, nil
+}
+
+func (f *fakeRooms) ReserveBatch(ctx context.Context, reservations []client.Reservation) ([]client.Reservation, []client.Conflict, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reserved++
+	conflicts := []client.Conflict{}
+	for i, reservation := range reservations {
+		for _, booking := range f.bookings {
+			if booking.RoomName == reservation.RoomName && booking.Date == reservation.Date &&
+				booking.Day == reservation.Day && booking.Start < reservation.End && reservation.Start < booking.End {
+				conflicts = append(conflicts, client.Conflict{Index: i, Reservation: reservation, Reason: "Already Reserved"})
+				break
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts, &client.Error{Status: 403, Code: client.CodeRoomReserved, Message: "Already Reserved"}
+	}
+
+	booked := make([]client.Reservation, 0, len(reservations))
+	for _, reservation := range reservations {
+		f.nextID++
+		reservation.ID = f.nextID
+		f.bookings[reservation.Reference] = reservation
+		booked = append(booked, reservation)
+	}
+	if f.reserveErr != nil {
+		return nil, nil, f.reserveErr
+	}
+	return booked, nil, nil
+}
+
+func (f *fakeRooms) ReleaseReferences(ctx context.Context, references ...string) ([]uint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.releaseErrs) > 0 {
+		err := f.releaseErrs[0]
+		f.releaseErrs = f.releaseErrs[1:]
+		return nil, err
+	}
+	released := []uint{}
+	for _, reference := range references {
+		if booking, ok := f.bookings[reference]; ok {
+			released = append(released, booking.ID)
+			delete(f.bookings, reference)
+		}
+	}
+	return released, nil
+}
+
+func (f *fakeRooms) Swap(ctx context.Context, old client.Reservation, replacement client.Reservation) (*client.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for reference, booking := range f.bookings {
+		if booking.ID == old.ID {
+			delete(f.bookings, reference)
+			f.nextID++
+			replacement.ID = f.nextID
+			f.bookings[replacement.Reference] = replacement
+			return &replacement, nil
+		}
+	}
+	return nil, &client.Error{Status: 404, Code: client.CodeReservationNotFound, Message: "Reservation does not exists"}
+}
+
+func (f *fakeRooms) Reservations(ctx context.Context) ([]client.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bookings := make([]client.Reservation, 0, len(f.bookings))
+	for _, booking := range f.bookings {
+		bookings = append(bookings, booking)
+	}
+	return bookings, nil
+}
+
+func (f *fakeRooms) Release(ctx context.Context, ids []uint) ([]uint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.releaseErrs) > 0 {
+		err := f.releaseErrs[0]
+		f.releaseErrs = f.releaseErrs[1:]
+		return nil, err
+	}
+
+	released := []uint{}
+	for _, id := range ids {
+		for reference, booking := range f.bookings {
+			if booking.ID == id {
+				released = append(released, id)
+				delete(f.bookings, reference)
+			}
+		}
+	}
+	return released, nil
+}
+
+func (f *fakeRooms) ReleaseMatching(ctx context.Context, reservations []client.Reservation) ([]uint, error) {
+	return []uint{}, nil
+}
+
+// booked returns the bookings the fake room server holds.
+func (f *fakeRooms) booked() map[string]client.Reservation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	booked := make(map[string]client.Reservation, len(f.bookings))
+	for reference, booking := range f.bookings {
+		booked[reference] = booking
+	}
+	return booked
+}
+
+// fakeActivities is an activity server that knows every activity.
+type fakeActivities struct{}
+
+func (fakeActivities) CheckActivity(ctx context.Context, name string) (*client.Activity, error) {
+	return &client.Activity{Name: name}, nil
+}
+
+// jsonRequest is a request that asks for a JSON answer.
+func jsonRequest(t *testing.T) *helper.Request {
+	t.Helper()
+
+	req, err := helper.ReadRequest(bufio.NewReader(strings.NewReader(
+		"POST /reserve HTTP/1.1\r\nAccept: application/json\r\nContent-Length: 0\r\n\r\n")))
+	if err != nil {
+		t.Fatalf("cannot make request: %v", err)
+	}
+	return req
+}
+
+// statusOf returns the status code of the response.
+func statusOf(t *testing.T, response string) int {
+	t.Helper()
+
+	var status int
+	_, err := fmt.Sscanf(response, "HTTP/1.0 %d", &status)
+	if err != nil {
+		t.Fatalf("cannot read status of response %q: %v", response, err)
+	}
+	return status
+}
+
+// reservationsIn returns every reservation of the database.
+func reservationsIn(t *testing.T) []models.RoomReservation {
+	t.Helper()
+
+	reservations, err := models.GetAllReservations()
+	if err != nil {
+		t.Fatalf("GetAllReservations: %v", err)
+	}
+	return reservations
+}
+
+func weeklyReservation() *models.RoomReservation {
+	return &models.RoomReservation{
+		RoomName:     "A101",
+		ActivityName: "Lecture",
+		Day:          1,
+		Start:        models.ClockOf(10, 0),
+		End:          models.ClockOf(11, 0),
+	}
+}
+
+func TestReserveConfirmsBooking(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, response := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+
+	saved := reservationsIn(t)
+	if len(saved) != 1 || saved[0].Status != models.StatusConfirmed {
+		t.Fatalf("got reservations %+v, want one confirmed", saved)
+	}
+	reference := fmt.Sprintf("reservation/%d", reservation.ID)
+	if saved[0].Reference != reference {
+		t.Errorf("got reference %q, want %q", saved[0].Reference, reference)
+	}
+	booking, ok := rooms.booked()[reference]
+	if !ok {
+		t.Fatalf("room is not booked with reference %q", reference)
+	}
+	if saved[0].RoomBookingID != booking.ID {
+		t.Errorf("got room booking id %d, want %d", saved[0].RoomBookingID, booking.ID)
+	}
+}
+
+func TestReservePendingInsertFails(t *testing.T) {
+	useTestDatabase(t)
+	failOn(t, "BEFORE INSERT ON room_reservations")
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, response := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if status := statusOf(t, response); status != 500 {
+		t.Errorf("got status %d, want 500: %s", status, response)
+	}
+	if reservation != nil {
+		t.Errorf("got reservation %+v, want none", reservation)
+	}
+	if rooms.reserved != 0 {
+		t.Errorf("room server was asked to book %d times, want none", rooms.reserved)
+	}
+}
+
+func TestReserveBookingTimesOutAfterCommit(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	rooms.reserveErr = context.DeadlineExceeded
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, response := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if status := statusOf(t, response); status != 504 {
+		t.Errorf("got status %d, want 504: %s", status, response)
+	}
+	if reservation != nil {
+		t.Errorf("got reservation %+v, want none", reservation)
+	}
+	if rooms.reserved != 1 {
+		t.Errorf("room server was asked to book %d times, want 1", rooms.reserved)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the pending one removed", saved)
+	}
+}
+
+func TestReserveConfirmFails(t *testing.T) {
+	useTestDatabase(t)
+	failOn(t, "BEFORE UPDATE OF status ON room_reservations WHEN NEW.status = 'confirmed'")
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	reservation, response := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if status := statusOf(t, response); status != 500 {
+		t.Errorf("got status %d, want 500: %s", status, response)
+	}
+	if reservation != nil {
+		t.Errorf("got reservation %+v, want none", reservation)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the pending one removed", saved)
+	}
+}
+
+func TestReserveCompensationFailsAndIsRetried(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	rooms.reserveErr = context.DeadlineExceeded
+	rooms.releaseErrs = []error{context.DeadlineExceeded}
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	_, response := b.reserve(jsonRequest(t), weeklyReservation(), nil)
+	if status := statusOf(t, response); status != 504 {
+		t.Errorf("got status %d, want 504: %s", status, response)
+	}
+
+	// the booking could not be released, the reservation stays pending
+	if booked := rooms.booked(); len(booked) != 1 {
+		t.Fatalf("got bookings %+v, want the one that could not be released", booked)
+	}
+	saved := reservationsIn(t)
+	if len(saved) != 1 || saved[0].Status != models.StatusPending {
+		t.Fatalf("got reservations %+v, want one pending", saved)
+	}
+
+	b.compensatePending()
+
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the pending one removed", saved)
+	}
+}
+
+func TestCompensatePendingSkipsRunningSagas(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	pending := []models.RoomReservation{*weeklyReservation()}
+	err := models.CreatePendingReservations(pending)
+	if err != nil {
+		t.Fatalf("CreatePendingReservations: %v", err)
+	}
+	sagas.Store(pending[0].ID, true)
+	defer sagas.Delete(pending[0].ID)
+
+	b.compensatePending()
+
+	if saved := reservationsIn(t); len(saved) != 1 {
+		t.Errorf("got reservations %+v, want the pending one of the running saga kept", saved)
+	}
+}
+
+// groupSlots are two rooms for a lecture every Monday at 10:00.
+func groupSlots() []models.RoomReservation {
+	return []models.RoomReservation{
+		{RoomName: "A101", Day: 1, Start: models.ClockOf(10, 0), End: models.ClockOf(11, 0)},
+		{RoomName: "B202", Day: 1, Start: models.ClockOf(10, 0), End: models.ClockOf(11, 0)},
+	}
+}
+
+func TestReserveGroupBooksEveryRoom(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	response := b.reserveGroup(jsonRequest(t), "Lecture", groupSlots())
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+
+	saved := reservationsIn(t)
+	if len(saved) != 2 {
+		t.Fatalf("got reservations %+v, want one for each room", saved)
+	}
+	booked := rooms.booked()
+	for _, reservation := range saved {
+		if reservation.Status != models.StatusConfirmed || reservation.ActivityName != "Lecture" {
+			t.Errorf("got reservation %+v, want a confirmed one for the lecture", reservation)
+		}
+		booking, ok := booked[reservation.Reference]
+		if !ok {
+			t.Errorf("reservation %d is not booked with its reference %q", reservation.ID, reservation.Reference)
+			continue
+		}
+		if booking.ID != reservation.RoomBookingID || booking.RoomName != reservation.RoomName {
+			t.Errorf("reservation %+v does not match its booking %+v", reservation, booking)
+		}
+	}
+}
+
+func TestReserveGroupConflictBooksNoRoom(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	// B202 is taken by someone else
+	_, _, err := rooms.ReserveBatch(context.Background(), []client.Reservation{{
+		RoomName: "B202", Day: 1, Start: models.ClockOf(10, 30), End: models.ClockOf(11, 30), Reference: "other",
+	}})
+	if err != nil {
+		t.Fatalf("ReserveBatch: %v", err)
+	}
+
+	response := b.reserveGroup(jsonRequest(t), "Lecture", groupSlots())
+	if status := statusOf(t, response); status != 403 {
+		t.Fatalf("got status %d, want 403: %s", status, response)
+	}
+	if !strings.Contains(response, `"error":"room_reserved"`) || !strings.Contains(response, `"room_name":"B202"`) {
+		t.Errorf("response does not relay the conflict of B202: %s", response)
+	}
+	if booked := rooms.booked(); len(booked) != 1 {
+		t.Errorf("got bookings %+v, want only the other one", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the pending ones removed", saved)
+	}
+}
+
+func TestReserveGroupConfirmFails(t *testing.T) {
+	useTestDatabase(t)
+	failOn(t, "BEFORE UPDATE OF status ON room_reservations WHEN NEW.status = 'confirmed'")
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	response := b.reserveGroup(jsonRequest(t), "Lecture", groupSlots())
+	if status := statusOf(t, response); status != 500 {
+		t.Errorf("got status %d, want 500: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("reservations %+v are left, want the pending ones removed", saved)
+	}
+}
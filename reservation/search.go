@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// HandleSearch finds the rooms that fit the capacity and have the equipment asked for, and are
+// free for the whole time window, by asking the room server. The search can be limited to a
+// building and one of its floors. The best fitting rooms are listed first.
+func HandleSearch(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body client.RoomSearch
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		var window models.RoomReservation
+		var err error
+		// a date searches that date only, a day of the week searches it every week
+		window.Date = req.Query.Get("date")
+		if window.Date == "" {
+			window.Day, err = req.IntParam("day")
+		}
+		if err == nil {
+			err = readTimeParams(req, &window)
+		}
+		if err == nil && req.Query.Get("capacity") != "" {
+			body.Capacity, err = req.IntParam("capacity")
+		}
+		if err == nil && req.Query.Get("floor") != "" {
+			var floor int
+			floor, err = req.IntParam("floor")
+			body.Floor = &floor
+		}
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser error.", err.Error())
+
+			return
+		}
+		if req.Query.Get("equipment") != "" {
+			body.Equipment = strings.Split(req.Query.Get("equipment"), ",")
+		}
+		body.Building = req.Query.Get("building")
+		body.Date = window.Date
+		body.Day = window.Day
+		body.Start = window.Start
+		body.End = window.End
+		body.Hour = window.Hour
+		body.Duration = window.Duration
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser error.", err.Error())
+
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	result, err := roomClient.SearchRooms(ctx, body)
+	if err != nil {
+		response = upstreamError(req, err)
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Reservation", "Free rooms.", searchDetails(result), result)
+}
+
+// searchDetails is the human readable listing of the rooms found by a search for the HTML pages.
+func searchDetails(result *client.RoomSearchResult) string {
+	details := strings.Builder{}
+	details.WriteString(fmt.Sprintf("Rooms free %s %s-%s, the best fitting first:\r\n",
+		dateOrEveryWeek(result.Date, result.Day), result.Start, result.End))
+	if len(result.Rooms) == 0 {
+		details.WriteString("None\r\n")
+	}
+	for i := range result.Rooms {
+		details.WriteString(roomLine(&result.Rooms[i]) + "\r\n")
+	}
+	details.WriteString("\r\n")
+	return details.String()
+}
+
+// roomLine is the human readable line of a room and its attributes for the HTML pages.
+func roomLine(room *client.Room) string {
+	line := room.Name
+	if room.Capacity > 0 {
+		line += fmt.Sprintf(", capacity %d", room.Capacity)
+	}
+	if room.Building != "" {
+		line += fmt.Sprintf(", building %s floor %d", room.Building, room.Floor)
+	}
+	if len(room.Equipment) > 0 {
+		line += ", equipment: " + strings.Join(room.Equipment, ", ")
+	}
+	return line
+}
+
+// alternativeDetails is the human readable listing of what can be reserved instead of a
+// reservation that conflicts for the HTML pages, after the reason of the conflict.
+func alternativeDetails(reason string, alternatives *client.Alternatives) string {
+	details := strings.Builder{}
+	details.WriteString(reason + "\r\n\r\nFree at another time:\r\n")
+	if len(alternatives.Slots) == 0 {
+		details.WriteString("None\r\n")
+	}
+	for _, slot := range alternatives.Slots {
+		details.WriteString(fmt.Sprintf("%s %s %s-%s\r\n", slot.RoomName, dateOrEveryWeek(slot.Date, slot.Day),
+			slot.Start, slot.End))
+	}
+	details.WriteString("\r\nFree at the same time:\r\n")
+	if len(alternatives.OtherRooms) == 0 {
+		details.WriteString("None\r\n")
+	}
+	for i := range alternatives.OtherRooms {
+		details.WriteString(roomLine(&alternatives.OtherRooms[i]) + "\r\n")
+	}
+	details.WriteString("\r\n")
+	return details.String()
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/client"
+	"github.com/yusufatalay/SocketProgramming/reservation/helper"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// HandleReserveSeries books every occurrence of a recurrence rule at once, or none of them
+// and lists the conflicting ones.
+func HandleReserveSeries(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		models.RoomReservation
+		RRule string `json:"rrule"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.RoomName = req.Query.Get("room")
+		body.ActivityName = req.Query.Get("activity")
+		body.Date = req.Query.Get("date")
+		body.RRule = req.Query.Get("rrule")
+		err := readTimeParams(req, &body.RoomReservation)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser error.", err.Error())
+
+			return
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser error.", err.Error())
+
+			return
+		}
+	}
+
+	// the series starts on its date, the rule gives the dates of the occurrences from there on
+	first, err := time.Parse(models.DateLayout, body.Date)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", "date should be in YYYY-MM-DD format")
+
+		return
+	}
+	rrule, err := models.ParseRRule(body.RRule)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", err.Error())
+
+		return
+	}
+	dates, err := rrule.Dates(first)
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", err.Error())
+
+		return
+	}
+	if len(dates) == 0 {
+		response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser error.", "rrule has no occurrences")
+
+		return
+	}
+
+	response = bookings.reserveSeries(req, &models.ReservationSeries{
+		ActivityName: body.ActivityName,
+		RoomName:     body.RoomName,
+		Date:         body.Date,
+		Start:        body.Start,
+		End:          body.End,
+		RRule:        body.RRule,
+	}, body.Hour, body.Duration, dates)
+}
+
+// reserveSeries books the occurrences on the dates and saves the series as a single saga, like
+// reserveGroup does for several rooms, the time of the series may be given in whole hours with
+// hour and duration. Every occurrence is booked with a reference of its own.
+func (b *booker) reserveSeries(req *helper.Request, series *models.ReservationSeries, hour int, duration int, dates []time.Time) string {
+	// keep a reconciliation from taking the bookings of a running saga for orphaned ones
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// check activity server if the activity exists
+	_, err := b.activities.CheckActivity(ctx, series.ActivityName)
+	if err != nil {
+		return upstreamError(req, err)
+	}
+
+	for _, date := range dates {
+		series.Occurrences = append(series.Occurrences, models.RoomReservation{
+			ActivityName: series.ActivityName,
+			RoomName:     series.RoomName,
+			Date:         date.Format(models.DateLayout),
+			Start:        series.Start,
+			End:          series.End,
+		})
+	}
+
+	sagaLock.Lock()
+	err = models.CreatePendingSeries(series)
+	if err == nil {
+		for i := range series.Occurrences {
+			sagas.Store(series.Occurrences[i].ID, true)
+		}
+	}
+	sagaLock.Unlock()
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+	pending := make([]*models.RoomReservation, 0, len(series.Occurrences))
+	occurrences := make([]client.Reservation, 0, len(series.Occurrences))
+	for i := range series.Occurrences {
+		occurrence := &series.Occurrences[i]
+		pending = append(pending, occurrence)
+		defer sagas.Delete(occurrence.ID)
+		occurrences = append(occurrences, client.Reservation{
+			RoomName:  occurrence.RoomName,
+			Date:      occurrence.Date,
+			Start:     occurrence.Start,
+			End:       occurrence.End,
+			Hour:      hour,
+			Duration:  duration,
+			Reference: occurrence.Reference,
+		})
+	}
+
+	// ask room server to book all of the occurrences
+	booked, conflicts, err := b.rooms.ReserveBatch(ctx, occurrences)
+	if err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Status < 500 {
+			// the room server refused, there are no bookings to release
+			for _, occurrence := range pending {
+				removePending(occurrence)
+			}
+		} else {
+			// the occurrences may have been booked before the call failed
+			b.compensate(pending...)
+		}
+		if len(conflicts) > 0 {
+			details := strings.Builder{}
+			details.WriteString("None of the occurrences are reserved, these ones are not available:\r\n")
+			for _, conflict := range conflicts {
+				details.WriteString(fmt.Sprintf("%s %s-%s: %s\r\n", conflict.Reservation.Date, conflict.Reservation.Start,
+					conflict.Reservation.End, conflict.Reason))
+			}
+			return helper.CreateErrorResponseWithData(req, "Reservation", 403, "Forbidden", helper.ErrRoomReserved,
+				"Room not available.", details.String(), conflicts)
+		}
+		return upstreamError(req, err)
+	}
+	if len(booked) != len(occurrences) {
+		b.compensate(pending...)
+		return helper.CreateErrorResponse(req, "Reservation", 502, "Bad Gateway", helper.ErrUpstream,
+			"Upstream error.", fmt.Sprintf("room server booked %d of the %d occurrences", len(booked), len(occurrences)))
+	}
+
+	// the room server fills the day of the occurrences and the time of ones given in whole hours
+	series.Start = booked[0].Start
+	series.End = booked[0].End
+	for i, booking := range booked {
+		series.Occurrences[i].Day = booking.Day
+		series.Occurrences[i].Start = booking.Start
+		series.Occurrences[i].End = booking.End
+		series.Occurrences[i].RoomBookingID = booking.ID
+	}
+	err = models.ConfirmSeries(series)
+	if err != nil {
+		b.compensate(pending...)
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+
+	return helper.CreateSuccessResponse(req, "Reservation",
+		"Reservation series successful.", seriesDetails(series, series.Occurrences), series)
+}
+
+// HandleCancelSeries cancels every occurrence of a series, or only the one on the given date.
+func HandleCancelSeries(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		ID   uint   `json:"id"`
+		Date string `json:"date"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		id, err := strconv.Atoi(req.Query.Get("id"))
+		if err != nil || id < 1 {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", "ID should be integer")
+
+			return
+		}
+		body.ID = uint(id)
+		body.Date = req.Query.Get("date")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Reservation", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	series, err := models.GetSeriesByID(body.ID)
+	if err != nil {
+		if err.Error() == "ReservationSeries does not exists" {
+			response = helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrReservationNotFound,
+				"Database error.", "Reservation series not found.")
+		} else {
+			response = helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database error.", err.Error())
+		}
+
+		return
+	}
+
+	cancelled := []models.RoomReservation{}
+	for _, occurrence := range series.Occurrences {
+		if body.Date == "" || occurrence.Date == body.Date {
+			cancelled = append(cancelled, occurrence)
+		}
+	}
+	if len(cancelled) == 0 {
+		response = helper.CreateErrorResponse(req, "Reservation", 404, "Not Found", helper.ErrReservationNotFound,
+			"Database error.", fmt.Sprintf("Reservation series has no occurrence on %s.", body.Date))
+
+		return
+	}
+
+	response = bookings.cancelOccurrences(req, series, cancelled)
+}
+
+// cancelOccurrences releases the room bookings of the occurrences of the series and removes
+// the occurrences.
+func (b *booker) cancelOccurrences(req *helper.Request, series *models.ReservationSeries, cancelled []models.RoomReservation) string {
+	// keep a reconciliation from seeing the room server changed but not the reservations
+	reconcileLock.RLock()
+	defer reconcileLock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	// free the time on the room server first, the occurrences stay if that fails. The bookings
+	// whose id is not known or outdated are found by their reference.
+	ids := []uint{}
+	for _, occurrence := range cancelled {
+		if occurrence.RoomBookingID != 0 {
+			ids = append(ids, occurrence.RoomBookingID)
+		}
+	}
+	released := map[uint]bool{}
+	if len(ids) > 0 {
+		releasedIDs, err := b.rooms.Release(ctx, ids)
+		if err != nil {
+			return upstreamError(req, err)
+		}
+		for _, id := range releasedIDs {
+			released[id] = true
+		}
+	}
+	references := []string{}
+	for _, occurrence := range cancelled {
+		if occurrence.Reference != "" && !released[occurrence.RoomBookingID] {
+			references = append(references, occurrence.Reference)
+		}
+	}
+	if len(references) > 0 {
+		_, err := b.rooms.ReleaseReferences(ctx, references...)
+		if err != nil {
+			return upstreamError(req, err)
+		}
+	}
+
+	err := models.RemoveOccurrences(series, cancelled)
+	if err != nil {
+		return helper.CreateErrorResponse(req, "Reservation", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database error.", err.Error())
+	}
+
+	series.Occurrences = cancelled
+	return helper.CreateSuccessResponse(req, "Reservation",
+		"Cancellation successful.", seriesDetails(series, cancelled), series)
+}
+
+// seriesDetails is the human readable listing of a series and the given occurrences of it for the HTML pages.
+func seriesDetails(series *models.ReservationSeries, occurrences []models.RoomReservation) string {
+	details := strings.Builder{}
+	details.WriteString(fmt.Sprintf("Series Details:\r\nSeries ID: %d\r\nRoom: %s\r\nActivity: %s\r\nRule: %s\r\nStart: %s\r\nEnd: %s\r\nOccurrences:\r\n",
+		series.ID, series.RoomName, series.ActivityName, series.RRule, series.Start, series.End))
+	for _, occurrence := range occurrences {
+		details.WriteString(fmt.Sprintf("Reservation ID %d on %s\r\n", occurrence.ID, occurrence.Date))
+	}
+	details.WriteString("\r\n")
+	return details.String()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/reservation/database"
+	"github.com/yusufatalay/SocketProgramming/reservation/models"
+)
+
+// weeklySeries is a series of a lecture on the three Mondays from 2030-01-07 on.
+func weeklySeries() (*models.ReservationSeries, []time.Time) {
+	first := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)
+	dates := []time.Time{first, first.AddDate(0, 0, 7), first.AddDate(0, 0, 14)}
+	return &models.ReservationSeries{
+		ActivityName: "Lecture",
+		RoomName:     "A101",
+		Date:         "2030-01-07",
+		Start:        models.ClockOf(10, 0),
+		End:          models.ClockOf(11, 0),
+		RRule:        "FREQ=WEEKLY;COUNT=3",
+	}, dates
+}
+
+// seriesIn counts the series of the database.
+func seriesIn(t *testing.T) int64 {
+	t.Helper()
+
+	var series int64
+	err := database.DBConn.Model(&models.ReservationSeries{}).Count(&series).Error
+	if err != nil {
+		t.Fatalf("count series: %v", err)
+	}
+	return series
+}
+
+func TestReserveSeriesBooksEveryOccurrenceWithItsReference(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	series, dates := weeklySeries()
+	response := b.reserveSeries(jsonRequest(t), series, 0, 0, dates)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+
+	saved, err := models.GetSeriesByID(series.ID)
+	if err != nil {
+		t.Fatalf("GetSeriesByID: %v", err)
+	}
+	if len(saved.Occurrences) != len(dates) {
+		t.Fatalf("got %d occurrences, want %d", len(saved.Occurrences), len(dates))
+	}
+	booked := rooms.booked()
+	for _, occurrence := range saved.Occurrences {
+		if occurrence.Status != models.StatusConfirmed {
+			t.Errorf("occurrence %d is %s, want confirmed", occurrence.ID, occurrence.Status)
+		}
+		booking, ok := booked[occurrence.Reference]
+		if !ok {
+			t.Errorf("occurrence %d is not booked with its reference %q", occurrence.ID, occurrence.Reference)
+			continue
+		}
+		if booking.ID != occurrence.RoomBookingID || booking.Date != occurrence.Date {
+			t.Errorf("occurrence %+v does not match its booking %+v", occurrence, booking)
+		}
+	}
+}
+
+func TestReserveSeriesBookingTimesOutAfterCommit(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	rooms.reserveErr = context.DeadlineExceeded
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	series, dates := weeklySeries()
+	response := b.reserveSeries(jsonRequest(t), series, 0, 0, dates)
+	if status := statusOf(t, response); status != 504 {
+		t.Errorf("got status %d, want 504: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("occurrences %+v are left, want the pending ones removed", saved)
+	}
+	if count := seriesIn(t); count != 0 {
+		t.Errorf("%d series are left, want the pending one removed", count)
+	}
+}
+
+func TestReserveSeriesCompensationIsRetried(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	rooms.reserveErr = context.DeadlineExceeded
+	rooms.releaseErrs = []error{context.DeadlineExceeded}
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	series, dates := weeklySeries()
+	b.reserveSeries(jsonRequest(t), series, 0, 0, dates)
+	if booked := rooms.booked(); len(booked) != len(dates) {
+		t.Fatalf("got bookings %+v, want the %d that could not be released", booked, len(dates))
+	}
+
+	b.compensatePending()
+
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("occurrences %+v are left, want the pending ones removed", saved)
+	}
+	if count := seriesIn(t); count != 0 {
+		t.Errorf("%d series are left, want the pending one removed", count)
+	}
+}
+
+func TestCancelOccurrencesReleasesUnknownBookingsByReference(t *testing.T) {
+	useTestDatabase(t)
+	rooms := newFakeRooms()
+	b := &booker{rooms: rooms, activities: fakeActivities{}}
+
+	series, dates := weeklySeries()
+	response := b.reserveSeries(jsonRequest(t), series, 0, 0, dates)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	// the id of the first occurrence's booking never arrived
+	err := database.DBConn.Model(&models.RoomReservation{}).Where("id = ?", series.Occurrences[0].ID).
+		Update("room_booking_id", 0).Error
+	if err != nil {
+		t.Fatalf("cannot forget the booking id: %v", err)
+	}
+
+	saved, err := models.GetSeriesByID(series.ID)
+	if err != nil {
+		t.Fatalf("GetSeriesByID: %v", err)
+	}
+	response = b.cancelOccurrences(jsonRequest(t), saved, saved.Occurrences)
+	if status := statusOf(t, response); status != 200 {
+		t.Fatalf("got status %d, want 200: %s", status, response)
+	}
+	if booked := rooms.booked(); len(booked) != 0 {
+		t.Errorf("bookings %+v are left on the room server, want them released", booked)
+	}
+	if saved := reservationsIn(t); len(saved) != 0 {
+		t.Errorf("occurrences %+v are left, want them removed", saved)
+	}
+	if count := seriesIn(t); count != 0 {
+		t.Errorf("%d series are left, want the cancelled one removed", count)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/room/helper"
+	"github.com/yusufatalay/SocketProgramming/room/models"
+)
+
+// HandleAddBlackout blacks out a time of a room, on a date or on a day every week, so it cannot
+// be reserved. The time should be free, the reservations sharing time with it are listed otherwise.
+func HandleAddBlackout(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body models.Blackout
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.RoomName = req.Query.Get("name")
+		body.Reason = req.Query.Get("reason")
+		// a date blacks out that date only, a day of the week blacks it out every week
+		var err error
+		body.Date = req.Query.Get("date")
+		if body.Date == "" {
+			body.Day, err = req.IntParam("day")
+		}
+		// the whole day when no time is given
+		if err == nil && req.Query.Get("start") != "" {
+			body.Start, err = models.ParseClock(req.Query.Get("start"))
+			if err != nil {
+				err = errors.New("start parameter: " + err.Error())
+			}
+		}
+		if err == nil && req.Query.Get("end") != "" {
+			body.End, err = models.ParseClock(req.Query.Get("end"))
+			if err != nil {
+				err = errors.New("end parameter: " + err.Error())
+			}
+		}
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
+
+			return
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	overlapping, err := models.CreateBlackout(&body)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "There is no room exists with the given name")
+
+			return
+		} else if err.Error() == "Already Reserved" {
+			reservationsStr := strings.Builder{}
+			reservationsStr.WriteString("Cancel or move these reservations first:\n")
+			for _, reservation := range overlapping {
+				reservationsStr.WriteString(fmt.Sprintf("Reservation %d: %s %s-%s\n", reservation.ID,
+					dateOrEveryWeek(reservation.Date, reservation.Day), reservation.Start, reservation.End))
+			}
+			response = helper.CreateErrorResponseWithData(req, "Room", 403, "Forbidden", helper.ErrRoomReserved,
+				"Room already reserved", reservationsStr.String(), overlapping)
+
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
+
+			return
+		}
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Room %s is blacked out for %s: %s %s-%s", body.RoomName, body.Reason,
+			dateOrEveryWeek(body.Date, body.Day), body.Start, body.End), body)
+}
+
+// HandleBlackouts lists the blackouts from now on, of the room with the given name or of every
+// room when no name is given.
+func HandleBlackouts(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Name string `json:"room_name"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
+
+				return
+			}
+		}
+	}
+
+	blackouts, err := models.GetBlackouts(body.Name)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "There is no room exists with the given name")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database Error", err.Error())
+		}
+
+		return
+	}
+
+	blackoutsStr := strings.Builder{}
+	if len(blackouts) == 0 {
+		blackoutsStr.WriteString("There are no blackouts.\n")
+	}
+	for _, blackout := range blackouts {
+		blackoutsStr.WriteString(fmt.Sprintf("Blackout %d: %s %s %s-%s, %s\n", blackout.ID, blackout.RoomName,
+			dateOrEveryWeek(blackout.Date, blackout.Day), blackout.Start, blackout.End, blackout.Reason))
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room", "Blackouts are listed below", blackoutsStr.String(), blackouts)
+}
+
+// HandleRemoveBlackout removes the blackout with the given id, its time can be reserved again.
+func HandleRemoveBlackout(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		ID uint `json:"id"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		id, err := req.IntParam("id")
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
+
+			return
+		}
+		body.ID = uint(id)
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	blackout, err := models.RemoveBlackout(body.ID)
+	if err != nil {
+		if err.Error() == "Blackout does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBlackoutNotFound,
+				"Blackout does not exists", "There is no blackout with the given id")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database Error", err.Error())
+		}
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", "Blackout removed successfully", blackout)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/room/helper"
+	"github.com/yusufatalay/SocketProgramming/room/models"
+)
+
+// HandleAddBuilding adds a building, rooms can be put in it on their floors afterwards.
+func HandleAddBuilding(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body models.Building
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+		body.Description = req.Query.Get("description")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	err := models.CreateBuilding(&body)
+	if err != nil {
+		if err.Error() == "Building already exists" {
+			response = helper.CreateErrorResponse(req, "Room", 403, "Forbidden", helper.ErrBuildingExists,
+				"Building already exists", "There is already a building exists with the same name")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
+		}
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Building %s successfully added to database", body.Name), body)
+}
+
+// HandleBuildings lists every building.
+func HandleBuildings(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	buildings, err := models.GetBuildings()
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Room", 500, "Internal Server Error", helper.ErrDatabase,
+			"Database Error", err.Error())
+
+		return
+	}
+
+	buildingsStr := strings.Builder{}
+	if len(buildings) == 0 {
+		buildingsStr.WriteString("There are no buildings.\n")
+	}
+	for _, building := range buildings {
+		buildingsStr.WriteString(buildingDetails(&building) + "\n")
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room", "Buildings are listed below", buildingsStr.String(), buildings)
+}
+
+// HandleBuilding shows a building along with its rooms floor by floor.
+func HandleBuilding(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Name string `json:"building_name"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	building, err := models.GetBuilding(body.Name)
+	if err != nil {
+		if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database Error", err.Error())
+		}
+
+		return
+	}
+
+	roomsStr := strings.Builder{}
+	roomsStr.WriteString(buildingDetails(&building.Building) + "\n\n")
+	if len(building.Floors) == 0 {
+		roomsStr.WriteString("The building has no rooms.\n")
+	}
+	for _, floor := range building.Floors {
+		roomsStr.WriteString(fmt.Sprintf("Floor %d:\n", floor.Floor))
+		for i := range floor.Rooms {
+			roomsStr.WriteString(roomDetails(&floor.Rooms[i]) + "\n")
+		}
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		fmt.Sprintf("Rooms of building %s are listed below", building.Name), roomsStr.String(), building)
+}
+
+// HandleRemoveBuilding removes a building, its rooms should be removed or moved to another
+// building first.
+func HandleRemoveBuilding(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Name string `json:"building_name"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+
+	err := models.RemoveBuilding(body.Name)
+	if err != nil {
+		if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name")
+		} else if err.Error() == "Building has rooms" {
+			response = helper.CreateErrorResponse(req, "Room", 403, "Forbidden", helper.ErrBuildingNotEmpty,
+				"Building has rooms", "Remove the rooms of the building or move them to another building first")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 500, "Internal Server Error", helper.ErrDatabase,
+				"Database Error", err.Error())
+		}
+
+		return
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Building %s removed successfully", body.Name), nil)
+}
+
+// HandleBuildingReservations lists the reservations taking place on the date, or from now on
+// when no date is given, grouped by building and floor. Only the building with the given name
+// is listed when there is one.
+func HandleBuildingReservations(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Building string `json:"building"`
+		Date     string `json:"date"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Building = req.Query.Get("building")
+		body.Date = req.Query.Get("date")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
+
+				return
+			}
+		}
+	}
+
+	buildings, err := models.GetReservationsByBuilding(body.Building, body.Date)
+	if err != nil {
+		if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name")
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
+		}
+
+		return
+	}
+
+	reservationsStr := strings.Builder{}
+	if len(buildings) == 0 {
+		reservationsStr.WriteString("There are no reservations.\n")
+	}
+	for i, building := range buildings {
+		if i > 0 {
+			reservationsStr.WriteString("\n")
+		}
+		if building.Name == "" {
+			reservationsStr.WriteString("Rooms without a building:\n")
+		} else {
+			reservationsStr.WriteString(buildingDetails(&building.Building) + ":\n")
+		}
+		for _, floor := range building.Floors {
+			if building.Name != "" {
+				reservationsStr.WriteString(fmt.Sprintf("Floor %d:\n", floor.Floor))
+			}
+			for _, room := range floor.Rooms {
+				for _, reservation := range room.Reservations {
+					reservationsStr.WriteString(fmt.Sprintf("Reservation %d: %s %s %s-%s\n", reservation.ID,
+						room.Name, dateOrEveryWeek(reservation.Date, reservation.Day), reservation.Start,
+						reservation.End))
+				}
+			}
+		}
+	}
+
+	title := "Reservations are listed below by building"
+	if body.Date != "" {
+		title = fmt.Sprintf("Reservations on %s are listed below by building", body.Date)
+	}
+	response = helper.CreateSuccessResponse(req, "Room", title, reservationsStr.String(), buildings)
+}
+
+// buildingDetails is the human readable line of a building for the HTML pages.
+func buildingDetails(building *models.Building) string {
+	if building.Description == "" {
+		return "Building " + building.Name
+	}
+	return fmt.Sprintf("Building %s (%s)", building.Name, building.Description)
+}
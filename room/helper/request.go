@@ -0,0 +1,153 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxBodySize is the biggest request body the server accepts, so a bogus Content-Length
+// or an endless chunked stream cannot make it allocate unbounded memory.
+const MaxBodySize = 1 << 20
+
+// Request is a parsed HTTP request, handlers consume this instead of the raw bytes.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Proto  string
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// ReadRequest reads a complete HTTP/1.x request from r. It parses the request line and the
+// headers, then reads the body according to Content-Length or chunked transfer encoding,
+// so requests split over several TCP segments are handled too.
+func ReadRequest(r *bufio.Reader) (*Request, error) {
+	tp := textproto.NewReader(r)
+
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed request line %q", line)
+	}
+	req := &Request{
+		Method: parts[0],
+		Proto:  parts[2],
+	}
+	if !strings.HasPrefix(req.Proto, "HTTP/1.") {
+		return nil, fmt.Errorf("unsupported protocol %q", req.Proto)
+	}
+
+	u, err := url.ParseRequestURI(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed request target %q: %v", parts[1], err)
+	}
+	req.Path = u.Path
+	req.Query, err = url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("malformed query string: %v", err)
+	}
+
+	// old clients close the connection right after the request line, treat that as the end of headers
+	req.Header, err = tp.ReadMIMEHeader()
+	if err != nil && !(errors.Is(err, io.EOF) && len(req.Header) == 0) {
+		return nil, fmt.Errorf("malformed headers: %v", err)
+	}
+
+	if strings.EqualFold(req.Header.Get("Transfer-Encoding"), "chunked") {
+		req.Body, err = readChunkedBody(tp)
+		if err != nil {
+			return nil, err
+		}
+	} else if cl := req.Header.Get("Content-Length"); cl != "" {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid Content-Length %q", cl)
+		}
+		if length > MaxBodySize {
+			return nil, errors.New("request body too large")
+		}
+		req.Body = make([]byte, length)
+		_, err = io.ReadFull(r, req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %v", err)
+		}
+	}
+
+	return req, nil
+}
+
+// readChunkedBody reads a body sent with "Transfer-Encoding: chunked" and discards the trailers.
+func readChunkedBody(tp *textproto.Reader) ([]byte, error) {
+	body := []byte{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return nil, fmt.Errorf("reading chunk size: %v", err)
+		}
+		// chunk extensions are allowed after a semicolon, we don't use them
+		sizeStr := strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+		size, err := strconv.ParseInt(sizeStr, 16, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid chunk size %q", sizeStr)
+		}
+		if size == 0 {
+			break
+		}
+		if int64(len(body))+size > MaxBodySize {
+			return nil, errors.New("request body too large")
+		}
+		chunk := make([]byte, size)
+		_, err = io.ReadFull(tp.R, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("reading chunk: %v", err)
+		}
+		body = append(body, chunk...)
+		// every chunk is followed by a CRLF
+		if end, err := tp.ReadLine(); err != nil || end != "" {
+			return nil, errors.New("malformed chunk terminator")
+		}
+	}
+	_, err := tp.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading trailers: %v", err)
+	}
+
+	return body, nil
+}
+
+// WantsJSON reports whether the client listed application/json in its Accept header,
+// everyone else (browsers mostly) gets the HTML pages.
+func (req *Request) WantsJSON() bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediatype := range strings.Split(accept, ",") {
+			mediatype = strings.TrimSpace(strings.SplitN(mediatype, ";", 2)[0])
+			if strings.EqualFold(mediatype, "application/json") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IntParam returns the named query parameter as a number, the error is meant to be shown to the client.
+func (req *Request) IntParam(name string) (int, error) {
+	value := req.Query.Get(name)
+	if value == "" {
+		return 0, fmt.Errorf("%s parameter is empty", name)
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s value should be a number", name)
+	}
+	return number, nil
+}
@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		// split makes every read return a single byte, like a request arriving in many segments
+		split     bool
+		wantPath  string
+		wantQuery string
+		wantBody  string
+		wantErr   string
+	}{
+		{"query", "GET /reserve?room=A101&day=1 HTTP/1.1\r\nHost: x\r\n\r\n", false, "/reserve", "A101", "", ""},
+		{"request line only", "GET /health HTTP/1.0\r\n", false, "/health", "", "", ""},
+		{"content length", "POST /add HTTP/1.1\r\nContent-Length: 11\r\n\r\n{\"a\":\"xyz\"}", false, "/add", "", "{\"a\":\"xyz\"}", ""},
+		{"body split across reads", "POST /add HTTP/1.1\r\nContent-Length: 11\r\n\r\n{\"a\":\"xyz\"}", true, "/add", "",
+			"{\"a\":\"xyz\"}", ""},
+		{"missing content length has no body", "POST /add HTTP/1.1\r\n\r\nignored", false, "/add", "", "", ""},
+		{"chunked", "POST /add HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nWiki\r\n5;ext=1\r\npedia\r\n0\r\nTrailer: x\r\n\r\n",
+			false, "/add", "", "Wikipedia", ""},
+		{"chunked split across reads", "POST /add HTTP/1.1\r\nTransfer-Encoding: Chunked\r\n\r\n4\r\nWiki\r\n5\r\npedia\r\n0\r\n\r\n",
+			true, "/add", "", "Wikipedia", ""},
+		{"content length over the limit", fmt.Sprintf("POST /add HTTP/1.1\r\nContent-Length: %d\r\n\r\n", MaxBodySize+1),
+			false, "", "", "", "request body too large"},
+		{"chunk over the limit", fmt.Sprintf("POST /add HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n", MaxBodySize+1),
+			false, "", "", "", "request body too large"},
+		{"invalid content length", "POST /add HTTP/1.1\r\nContent-Length: ten\r\n\r\n", false, "", "", "", `invalid Content-Length "ten"`},
+		{"negative content length", "POST /add HTTP/1.1\r\nContent-Length: -1\r\n\r\n", false, "", "", "", `invalid Content-Length "-1"`},
+		{"body shorter than content length", "POST /add HTTP/1.1\r\nContent-Length: 10\r\n\r\nabc", false, "", "", "",
+			"reading body: unexpected EOF"},
+		{"invalid chunk size", "POST /add HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", false, "", "", "",
+			`invalid chunk size "zz"`},
+		{"missing chunk terminator", "POST /add HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabcdef\r\n", false, "", "", "",
+			"malformed chunk terminator"},
+		{"empty", "", false, "", "", "", "EOF"},
+		{"missing protocol", "GET /\r\n\r\n", false, "", "", "", `malformed request line "GET /"`},
+		{"extra part", "GET / HTTP/1.1 x\r\n\r\n", false, "", "", "", `malformed request line "GET / HTTP/1.1 x"`},
+		{"unsupported protocol", "GET / HTTP/2\r\n\r\n", false, "", "", "", `unsupported protocol "HTTP/2"`},
+		{"relative target", "GET reserve HTTP/1.1\r\n\r\n", false, "", "", "", `malformed request target "reserve"`},
+		{"malformed query", "GET /reserve?room=%zz HTTP/1.1\r\n\r\n", false, "", "", "", "malformed query string"},
+		{"malformed header", "GET / HTTP/1.1\r\nno colon\r\n\r\n", false, "", "", "", "malformed headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(tt.raw)
+			if tt.split {
+				r = iotest.OneByteReader(r)
+			}
+			req, err := ReadRequest(bufio.NewReader(r))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadRequest(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadRequest(%q) error = %v", tt.raw, err)
+			}
+			if req.Path != tt.wantPath {
+				t.Errorf("got path %q, want %q", req.Path, tt.wantPath)
+			}
+			if got := req.Query.Get("room"); got != tt.wantQuery {
+				t.Errorf("got room %q, want %q", got, tt.wantQuery)
+			}
+			if string(req.Body) != tt.wantBody {
+				t.Errorf("got body %q, want %q", req.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+
+	"github.com/yusufatalay/SocketProgramming/room/helper"
+	"github.com/yusufatalay/SocketProgramming/room/models"
+)
+
+// The internal API is meant for the other servers, not for the browsers. It always
+// answers with a helper.APIResponse document in JSON, its data field holds:
+//
+//	GET  /internal/v1/reservations                                    []models.Reservation (all of them)
+//	POST /internal/v1/reservations                                    models.Reservation (the created one)
+//	POST /internal/v1/reservations/batch                              []models.Reservation (the created ones)
+//	POST /internal/v1/reservations/release                            releasedReservations
+//	POST /internal/v1/reservations/swap                               models.Reservation (the replacement)
+//	GET  /internal/v1/availability?room=<name>&day=<d>                models.DayAvailability
+//	GET  /internal/v1/availability?room=<name>&date=<date>            models.DayAvailability
+//	GET  /internal/v1/availability?room=<name>&from=<date>&to=<date>  models.RangeAvailability
+//	GET  /internal/v1/weeklyavailability?room=<name>                  models.WeeklyAvailability
+//	POST /internal/v1/rooms/search                                    models.RoomSearchResult
+//
+// Dates are ISO 8601 calendar dates such as 2022-11-25.
+//
+// A reservation that conflicts, either with another one or with a blackout of the room, is
+// answered 409 Conflict with the models.Alternatives that can be reserved instead in its data
+// field.
+//
+// A batch is made all or nothing, when some of it conflicts the answer is 409 Conflict with
+// the []models.Conflict in its data field.
+//
+// Clients should switch on the error field, the messages are for humans only.
+const internalAPIPrefix = "/internal/v1"
+
+// registerInternalAPI adds the version 1 internal endpoints to the router.
+func registerInternalAPI(router *helper.Router) {
+	router.Handle(internalAPIPrefix+"/health", internalHealth, "GET")
+	router.Handle(internalAPIPrefix+"/reservations", internalListReservations, "GET")
+	router.Handle(internalAPIPrefix+"/reservations", internalCreateReservation, "POST")
+	router.Handle(internalAPIPrefix+"/reservations/batch", internalCreateReservations, "POST")
+	router.Handle(internalAPIPrefix+"/reservations/release", internalReleaseReservations, "POST")
+	router.Handle(internalAPIPrefix+"/reservations/swap", internalSwapReservation, "POST")
+	router.Handle(internalAPIPrefix+"/availability", internalAvailability, "GET")
+	router.Handle(internalAPIPrefix+"/weeklyavailability", internalWeeklyAvailability, "GET")
+	router.Handle(internalAPIPrefix+"/rooms/search", internalSearchRooms, "POST")
+}
+
+// writeInternal writes the response and closes the connection.
+func writeInternal(conn *net.Conn, response string) {
+	_, err := (*conn).Write([]byte(response))
+	if err != nil {
+		log.Printf("Error: %+v", err)
+	}
+	(*conn).Close()
+}
+
+func internalHealth(conn *net.Conn, req *helper.Request) {
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Room Server is healthy", nil))
+}
+
+func internalListReservations(conn *net.Conn, req *helper.Request) {
+	reservations, err := models.GetAllReservations()
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(500, "Internal Server Error", helper.ErrDatabase, err.Error(), nil))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "", reservations))
+}
+
+func internalCreateReservation(conn *net.Conn, req *helper.Request) {
+	var reservation models.Reservation
+	err := json.Unmarshal(req.Body, &reservation)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+
+	err = models.CreateReservation(&reservation)
+	if err != nil && (err.Error() == "Already Reserved" || err.Error() == "Room is blacked out") {
+		// offer what can be reserved instead, the conflict is reported even if that fails
+		alternatives, altErr := models.FindAlternatives(&reservation)
+		if altErr != nil {
+			log.Printf("Error: %+v", altErr)
+		}
+		code := helper.ErrRoomReserved
+		if err.Error() == "Room is blacked out" {
+			code = helper.ErrRoomBlackedOut
+		}
+		writeInternal(conn, helper.CreateJSONResponse(409, "Conflict", code, err.Error(), alternatives))
+
+		return
+	}
+	if err != nil {
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservation created successfully", reservation))
+}
+
+func internalCreateReservations(conn *net.Conn, req *helper.Request) {
+	var reservations []models.Reservation
+	err := json.Unmarshal(req.Body, &reservations)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+	if len(reservations) == 0 {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody,
+			"batch has no reservations", nil))
+
+		return
+	}
+
+	conflicts, err := models.CreateReservations(reservations)
+	if err != nil {
+		if len(conflicts) > 0 {
+			writeInternal(conn, helper.CreateJSONResponse(409, "Conflict", helper.ErrRoomReserved, err.Error(), conflicts))
+
+			return
+		}
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservations created successfully", reservations))
+}
+
+// releasedReservations is the answer of the release endpoint, its body is a models.Release.
+type releasedReservations struct {
+	IDs []uint `json:"ids"`
+}
+
+func internalReleaseReservations(conn *net.Conn, req *helper.Request) {
+	var body models.Release
+	err := json.Unmarshal(req.Body, &body)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+
+	released, err := models.ReleaseReservations(body)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(500, "Internal Server Error", helper.ErrDatabase, err.Error(), nil))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservations released successfully",
+		releasedReservations{IDs: released}))
+}
+
+// swappedReservation is the body of the swap endpoint, the old reservation is found by its id
+// or when that is zero by its room, date, day, start and end.
+type swappedReservation struct {
+	Old         models.Reservation `json:"old"`
+	Replacement models.Reservation `json:"replacement"`
+}
+
+func internalSwapReservation(conn *net.Conn, req *helper.Request) {
+	var body swappedReservation
+	err := json.Unmarshal(req.Body, &body)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+
+	err = models.SwapReservation(&body.Old, &body.Replacement)
+	if err != nil {
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "Reservation swapped successfully", body.Replacement))
+}
+
+func internalAvailability(conn *net.Conn, req *helper.Request) {
+	query, err := readAvailabilityQuery(req, "room")
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidParameter, err.Error(), nil))
+
+		return
+	}
+
+	availability, _, err := query.run()
+	if err != nil {
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "", availability))
+}
+
+func internalWeeklyAvailability(conn *net.Conn, req *helper.Request) {
+	roomname := req.Query.Get("room")
+	weekly, err := models.GetAllAvailableHours(roomname)
+	if err != nil {
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "", weekly))
+}
+
+// internalSearchRooms takes a models.RoomSearch body.
+func internalSearchRooms(conn *net.Conn, req *helper.Request) {
+	var search models.RoomSearch
+	err := json.Unmarshal(req.Body, &search)
+	if err != nil {
+		writeInternal(conn, helper.CreateJSONResponse(400, "Bad Request", helper.ErrInvalidBody, err.Error(), nil))
+
+		return
+	}
+
+	result, err := models.SearchRooms(&search)
+	if err != nil {
+		writeInternal(conn, internalModelError(err))
+
+		return
+	}
+
+	writeInternal(conn, helper.CreateJSONResponse(200, "OK", "", "", result))
+}
+
+// internalModelError maps the errors of the models package to the internal API error codes.
+func internalModelError(err error) string {
+	switch err.Error() {
+	case "Room does not exists":
+		return helper.CreateJSONResponse(404, "Not Found", helper.ErrRoomNotFound, err.Error(), nil)
+	case "Building does not exists":
+		return helper.CreateJSONResponse(404, "Not Found", helper.ErrBuildingNotFound, err.Error(), nil)
+	case "Reservation does not exists":
+		return helper.CreateJSONResponse(404, "Not Found", helper.ErrReservationNotFound, err.Error(), nil)
+	case "Already Reserved":
+		return helper.CreateJSONResponse(409, "Conflict", helper.ErrRoomReserved, err.Error(), nil)
+	case "Room is blacked out":
+		return helper.CreateJSONResponse(409, "Conflict", helper.ErrRoomBlackedOut, err.Error(), nil)
+	default:
+		return helper.CreateJSONResponse(400, "Bad Request", helper.ErrValidation, err.Error(), nil)
+	}
+}
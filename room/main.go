@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,12 +10,16 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/yusufatalay/SocketProgramming/room/helper"
 	"github.com/yusufatalay/SocketProgramming/room/models"
 )
 
+// readTimeout is how long a client has to send its whole request.
+const readTimeout = 10 * time.Second
+
 func main() {
 
 	// open the config file to write this serve's information
@@ -27,20 +31,56 @@ func main() {
 		panic(errors.New("Should only provide a port number."))
 	}
 
-	// insert this server's port number to config file
-	godotenv.Write(map[string]string{
-		"ROOMSERVERPORT":     os.Args[1],
-		"ACTIVITYSERVERPORT": os.Getenv("ACTIVITYSERVERPORT"),
-	}, "../.env")
+	// insert this server's port number to config file, keeping the other settings in it
+	config, err := godotenv.Read("../.env")
+	if err != nil {
+		log.Fatalf("cannot read project's dotenv file: %v\n", err)
+	}
+	config["ROOMSERVERPORT"] = os.Args[1]
+	err = godotenv.Write(config, "../.env")
+	if err != nil {
+		log.Fatalf("cannot write project's dotenv file: %v\n", err)
+	}
+
+	// reservations are made in whole hours unless the deployment configures another slot size
+	if os.Getenv("SLOTMINUTES") != "" {
+		err = models.SetSlotMinutes(os.Getenv("SLOTMINUTES"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// create a tcp socket that listens localhost:PORT
-	ln, err := net.Listen("tcp", "localhost:"+os.Getenv("ROOMSERVERPORT"))
+	ln, err := net.Listen("tcp", "localhost:"+os.Args[1])
 	if err != nil {
 		log.Fatal(err)
 	}
 	// deferred close the connection
 	defer ln.Close()
 
+	// every endpoint is matched exactly, with the methods it supports
+	router := helper.NewRouter("Room")
+	router.Handle("/health", health, "GET")
+	router.Handle("/add", HandleAdd, "GET", "POST")
+	router.Handle("/remove", HandleRemove, "GET", "POST")
+	router.Handle("/update", HandleUpdate, "GET", "POST")
+	router.Handle("/reserve", HandleReserve, "GET", "POST")
+	router.Handle("/cancel", HandleCancel, "GET", "POST")
+	router.Handle("/checkavailability", HandleCheckAvailability, "GET", "POST")
+	router.Handle("/checkweeklyavailability", HandleCheckWeeklyAvailability, "GET", "POST")
+	router.Handle("/rooms", HandleRooms, "GET", "POST")
+	router.Handle("/room", HandleRoom, "GET", "POST")
+	router.Handle("/search", HandleSearch, "GET", "POST")
+	router.Handle("/addblackout", HandleAddBlackout, "GET", "POST")
+	router.Handle("/blackouts", HandleBlackouts, "GET", "POST")
+	router.Handle("/removeblackout", HandleRemoveBlackout, "GET", "POST")
+	router.Handle("/addbuilding", HandleAddBuilding, "GET", "POST")
+	router.Handle("/buildings", HandleBuildings, "GET", "POST")
+	router.Handle("/building", HandleBuilding, "GET", "POST")
+	router.Handle("/removebuilding", HandleRemoveBuilding, "GET", "POST")
+	router.Handle("/reservations", HandleBuildingReservations, "GET", "POST")
+	registerInternalAPI(router)
+
 	//	program loop
 	for {
 		// accept connection
@@ -49,53 +89,34 @@ func main() {
 			log.Fatal(err)
 		}
 		// create new thread for each connection request to handle concurrency
-		go handleConnection(conn)
+		go handleConnection(conn, router)
 	}
 
 }
-func handleConnection(conn net.Conn) {
-	// Use bufio to read the request
-	buf := make([]byte, 1024)
-	_, err := conn.Read(buf)
+func handleConnection(conn net.Conn, router *helper.Router) {
+	// give slow or stuck clients a limited time to send their request
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+	// Parse the request
+	req, err := helper.ReadRequest(bufio.NewReader(conn))
 	if err != nil {
+		response := helper.CreateErrorResponse(nil, "Room", 400, "Bad Request", helper.ErrMalformedRequest,
+			"Malformed Request", err.Error())
+		conn.Write([]byte(response))
+		conn.Close()
 
 		return
 	}
 
-	// Parse the request
-
-	reqStr := string(buf)
-	reqParts := strings.Split(reqStr, "\n")
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-
 	// use appropriate handler for the url
-	if strings.HasPrefix(url, "/health") {
-		health(&conn)
-	} else if strings.HasPrefix(url, "/add") {
-		HandleAdd(&conn, reqStr)
-
-	} else if strings.HasPrefix(url, "/remove") {
-		HandleRemove(&conn, reqStr)
-
-	} else if strings.HasPrefix(url, "/reserve") {
-		HandleReserve(&conn, reqStr)
-	} else if strings.HasPrefix(url, "/checkavailability") {
-		HandleCheckAvailability(&conn, reqStr)
-	} else if strings.HasPrefix(url, "/checkweeklyavailability") {
-		HandleCheckWeeklyAvailability(&conn, reqStr)
-	}
-
+	router.Serve(&conn, req)
 }
 
 // health is for letting the controller server to know that this server is active.
-func health(conn *net.Conn) {
+func health(conn *net.Conn, req *helper.Request) {
 	// just return 200 OK to let the caller know this server is active
-
-	response := "HTTP/1.0 200 OK\r\n"
-	response += "Content-Type: text/plain\r\n"
-	response += "\r\n"
-	response += "Room Server is healty at port -> " + os.Getenv("ROOMSERVERPORT")
-	response += "\r\n"
+	response := helper.CreateSuccessResponse(req, "Room",
+		"Healthy", "Room Server is healty at port -> "+os.Getenv("ROOMSERVERPORT"), nil)
 	_, err := (*conn).Write([]byte(response))
 	if err != nil {
 		log.Fatal(err)
@@ -105,10 +126,8 @@ func health(conn *net.Conn) {
 }
 
 // HandleAdd will be triggerred when localhost/add has been visited.
-func HandleAdd(conn *net.Conn, req string) {
+func HandleAdd(conn *net.Conn, req *helper.Request) {
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -117,94 +136,73 @@ func HandleAdd(conn *net.Conn, req string) {
 		}
 		(*conn).Close()
 	}()
-	switch method {
+
+	var body models.Room
+	switch req.Method {
 	case "GET":
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
 		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
-
-		roomname := strings.Split(url, "=")[1]
-		// return error if roomname has not given
-		if roomname == "" {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Empty Parameter", "name parameter is empty")
+		body.Name = req.Query.Get("name")
+		var attributes models.RoomUpdate
+		err := readRoomAttributes(req, &attributes)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
 			return
 		}
-		// create a room instance with the given name
-		r := &models.Room{
-			Name: roomname,
-		}
-		// create the room in database
-		err := models.CreateRoom(r)
-
+		attributes.Apply(&body)
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			if err.Error() == "Room already exists" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room already exists", "There is already a room exists with the same name")
-
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
-
-				return
-			}
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
+			return
 		}
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", fmt.Sprintf("Room %s successfully added to database", roomname))
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Empty Parameter", "name parameter is empty")
 
 		return
+	}
+	err := body.Validate()
+	if err != nil {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+			"Validation Error", err.Error())
 
-	case "POST":
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00")
-		// POST request has been made, read the request body
-		// unmarshall the json body to a struct
-		var body models.Room
-		err := json.Unmarshal(reqBodyByte, &body)
-		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", err.Error())
+		return
+	}
 
-			return
-		}
-		err = models.CreateRoom(&body)
-		if err != nil {
-			if err.Error() == "Room already exists" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room already exists", "There is already a room exists with the same name")
+	// create the room in database
+	err = models.CreateRoom(&body)
+	if err != nil {
+		if err.Error() == "Room already exists" {
+			response = helper.CreateErrorResponse(req, "Room", 403, "Forbidden", helper.ErrRoomExists,
+				"Room already exists", "There is already a room exists with the same name")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
+			return
+		} else if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name, add it first")
 
-				return
-			}
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
+			return
 		}
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", fmt.Sprintf("Room %s successfully added to database", body.Name))
-
-		return
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-			"Method not supported", "Method not supported")
 
-		return
 	}
-
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Room %s successfully added to database", body.Name), body)
 }
 
-func HandleRemove(conn *net.Conn, req string) {
+func HandleRemove(conn *net.Conn, req *helper.Request) {
 
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -214,84 +212,49 @@ func HandleRemove(conn *net.Conn, req string) {
 		(*conn).Close()
 	}()
 
-	switch method {
+	var body models.Room
+	switch req.Method {
 	case "GET":
-
-		roomname := strings.Split(url, "=")[1]
-		// return error if roomname has not given
-		if roomname == "" {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Empty Parameter", "name parameter is empty")
-
-			return
-		}
-
-		err := models.RemoveRoom(roomname)
-		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room does not exists", "There is no room exists with the given name")
-
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
-
-				return
-			}
-
-		}
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", fmt.Sprintf("Room %s successfully removed from database", roomname))
-
-		return
-
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
 	case "POST":
-		// POST request has been made, read the request body
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-
-		var body models.Room
-		err := json.Unmarshal(reqBodyByte, &body)
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
 				"Parser Error", err.Error())
 
 			return
 		}
-		err = models.RemoveRoom(body.Name)
-		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room does not exists", "There is no room exists with the given name")
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Empty Parameter", "name parameter is empty")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
+		return
+	}
 
-				return
-			}
+	err := models.RemoveRoom(body.Name)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 403, "Forbidden", helper.ErrRoomNotFound,
+				"Room does not exists", "There is no room exists with the given name")
 
-		}
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", fmt.Sprintf("Room %s successfully removed from database", body.Name))
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
-		return
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+			return
+		}
 
-		return
 	}
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Room %s successfully removed from database", body.Name), body)
 }
 
-func HandleReserve(conn *net.Conn, req string) {
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
-	url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
+func HandleReserve(conn *net.Conn, req *helper.Request) {
 	response := ""
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
@@ -301,144 +264,210 @@ func HandleReserve(conn *net.Conn, req string) {
 		(*conn).Close()
 	}()
 
-	switch method {
+	var body models.Reservation
+	switch req.Method {
 	case "GET":
 		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
-		}
+		body.RoomName = req.Query.Get("name")
 		// return error if roomname has not given
-		if _, ok := paramsMap["name"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+		if body.RoomName == "" {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
 				"Empty Parameter", "name parameter is empty")
 
-			return
-		} else if _, ok := paramsMap["day"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Empty Parameter", "day parameter is empty")
-
-			return
-		} else if _, ok := paramsMap["hour"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Empty Parameter", "hour parameter is empty")
-			return
-		} else if _, ok := paramsMap["duration"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Empty Parameter", "duration parameter is empty")
-
 			return
 		}
-
-		dayint, err := strconv.Atoi(paramsMap["day"])
-		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "day value should be a number")
-
-			return
+		// a date books that date only, a day of the week books it every week
+		var err error
+		body.Date = req.Query.Get("date")
+		if body.Date == "" {
+			body.Day, err = req.IntParam("day")
+		}
+		if err == nil {
+			err = readTimeParams(req, &body)
 		}
-		hourint, err := strconv.Atoi(paramsMap["hour"])
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "hour value should be a number")
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
 			return
 		}
-
-		durationint, err := strconv.Atoi(paramsMap["duration"])
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "duration value should be a number")
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
 			return
 		}
-		err = models.CreateReservation(&models.Reservation{
-			RoomName: paramsMap["name"],
-			Day:      dayint,
-			Hour:     hourint,
-			Duration: durationint,
-		})
-		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room does not exists", "There is no room exists with the given name")
+	}
 
-				return
-			} else if err.Error() == "Already Reserved" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room already reserved", "Room already reserved in given time slice")
+	err := models.CreateReservation(&body)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "There is no room exists with the given name")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
+			return
+		} else if err.Error() == "Already Reserved" || err.Error() == "Room is blacked out" {
+			code, title, message := helper.ErrRoomReserved, "Room already reserved", "Room already reserved in given time slice"
+			if err.Error() == "Room is blacked out" {
+				code, title, message = helper.ErrRoomBlackedOut, "Room blacked out", "Room is blacked out in given time slice"
+			}
+			alternatives, altErr := models.FindAlternatives(&body)
+			if altErr != nil {
+				log.Printf("Error: %+v", altErr)
+				response = helper.CreateErrorResponse(req, "Room", 403, "Forbidden", code, title, message)
 
 				return
 			}
-		}
+			response = helper.CreateErrorResponseWithData(req, "Room", 403, "Forbidden", code,
+				title, message+"\n\n"+alternativeDetails(alternatives), alternatives)
 
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", "Reservation created successfully")
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
 
-		return
+			return
+		}
+	}
 
-	case "POST":
-		// POST request has been made, read the request body
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", "Reservation created successfully", body)
+}
 
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-		var body models.Reservation
+func HandleCancel(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Fatal(err)
+		}
+		(*conn).Close()
+	}()
 
-		err := json.Unmarshal(reqBodyByte, &body)
+	var body struct {
+		ID uint `json:"id"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		id, err := req.IntParam("id")
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
 				"Parser Error", err.Error())
 
 			return
 		}
-
-		err = models.CreateReservation(&body)
+		body.ID = uint(id)
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Room does not exists", "There is no room exists with the given name")
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
-				return
-			} else if err.Error() == "Already Reserved" {
-				response = helper.CreateHTTPResponse("Room", 403, "Forbidden",
-					"Room already reserved", "Room already reserved in given time slice")
+			return
+		}
+	}
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Database Error", err.Error())
+	reservation, err := models.RemoveReservation(body.ID)
+	if err != nil {
+		if err.Error() == "Reservation does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrReservationNotFound,
+				"Reservation does not exists", "There is no reservation with the given id")
 
-				return
-			}
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
+
+			return
 		}
+	}
 
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			"Succesfull", "Reservation created successfully")
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", "Reservation cancelled successfully", reservation)
+}
 
-		return
+// readTimeParams reads the time of a reservation from the start and end parameters, or
+// from the hour and duration parameters when it is given in whole hours.
+func readTimeParams(req *helper.Request, reservation *models.Reservation) error {
+	if req.Query.Get("start") == "" && req.Query.Get("end") == "" {
+		var err error
+		reservation.Hour, err = req.IntParam("hour")
+		if err != nil {
+			return err
+		}
+		reservation.Duration, err = req.IntParam("duration")
+		return err
+	}
 
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+	var err error
+	reservation.Start, err = models.ParseClock(req.Query.Get("start"))
+	if err != nil {
+		return errors.New("start parameter: " + err.Error())
+	}
+	reservation.End, err = models.ParseClock(req.Query.Get("end"))
+	if err != nil {
+		return errors.New("end parameter: " + err.Error())
+	}
+	return nil
+}
 
-		return
+// availabilityQuery selects what a room's availability is checked for, a date, a date range
+// from From to To, or else a day of the week.
+type availabilityQuery struct {
+	Name string `json:"room_name"`
+	Day  int    `json:"day"`
+	Date string `json:"date"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// readAvailabilityQuery reads the query from the URL parameters, the room is read from
+// the roomparam parameter.
+func readAvailabilityQuery(req *helper.Request, roomparam string) (availabilityQuery, error) {
+	query := availabilityQuery{
+		Name: req.Query.Get(roomparam),
+		Date: req.Query.Get("date"),
+		From: req.Query.Get("from"),
+		To:   req.Query.Get("to"),
+	}
+	var err error
+	if query.Date == "" && query.From == "" && query.To == "" {
+		query.Day, err = req.IntParam("day")
 	}
+	return query, err
+}
 
+// run returns the availability as a *models.DayAvailability or a *models.RangeAvailability,
+// along with the days it covers.
+func (query availabilityQuery) run() (interface{}, []models.DayAvailability, error) {
+	switch {
+	case query.From != "" || query.To != "":
+		availability, err := models.GetRangeAvailability(query.Name, query.From, query.To)
+		if err != nil {
+			return nil, nil, err
+		}
+		return availability, availability.Days, nil
+	case query.Date != "":
+		availability, err := models.GetDateAvailability(query.Name, query.Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		return availability, []models.DayAvailability{*availability}, nil
+	default:
+		availability, err := models.GetAvailableHours(query.Name, query.Day)
+		if err != nil {
+			return nil, nil, err
+		}
+		return availability, []models.DayAvailability{*availability}, nil
+	}
 }
 
-func HandleCheckAvailability(conn *net.Conn, req string) {
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
+func HandleCheckAvailability(conn *net.Conn, req *helper.Request) {
 	response := ""
 
 	defer func() {
@@ -448,245 +477,555 @@ func HandleCheckAvailability(conn *net.Conn, req string) {
 		}
 		(*conn).Close()
 	}()
-	switch method {
-	case "GET":
-		// GET request has been made, read the query params from the URL
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
 
+	var body availabilityQuery
+	switch req.Method {
+	case "GET":
 		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
-		}
+		var err error
+		body, err = readAvailabilityQuery(req, "name")
 		// return error if roomname has not given
-		if _, ok := paramsMap["name"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+		if body.Name == "" {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
 				"Parser Error", "name parameter is empty")
 
 			return
-		} else if _, ok := paramsMap["day"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "day parameter is empty")
+		}
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
 			return
 		}
-		dayint, err := strconv.Atoi(paramsMap["day"])
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "day value should be a number")
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
 
 			return
 		}
-		hours, err := models.GetAvailableHours(paramsMap["name"], dayint)
+	}
 
-		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 404, "Not Found",
-					"Room does not exists", "Room does not exists")
+	availability, days, err := body.run()
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "Room does not exists")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Validation Error", err.Error())
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
 
-				return
-			}
+			return
 		}
+	}
 
-		hoursStr := strings.Builder{}
-		for _, h := range hours {
-			hoursStr.WriteString(h + " ")
+	hoursStr := strings.Builder{}
+	for _, day := range days {
+		// a single day is listed on its own, the days of a range are labeled with their dates
+		if len(days) > 1 {
+			hoursStr.WriteString(day.Date + ": ")
+		}
+		if day.Closed {
+			hoursStr.WriteString("closed")
+		}
+		for _, slot := range day.Slots {
+			hoursStr.WriteString(slot.String() + " ")
 		}
 		hoursStr.WriteRune('\n')
+	}
 
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			fmt.Sprintf("Available hours for room %s for day %s is listed below",
-				paramsMap["name"], paramsMap["day"]), hoursStr.String())
+	title := fmt.Sprintf("Available hours for room %s for day %d is listed below", body.Name, body.Day)
+	if body.From != "" || body.To != "" {
+		title = fmt.Sprintf("Available hours for room %s from %s to %s is listed below", body.Name, body.From, body.To)
+	} else if body.Date != "" {
+		title = fmt.Sprintf("Available hours for room %s on %s is listed below", body.Name, body.Date)
+	}
 
-		return
+	response = helper.CreateSuccessResponse(req, "Room", title, hoursStr.String(), availability)
+}
 
-	case "POST":
-		// POST request has been made, read the request body
+func HandleCheckWeeklyAvailability(conn *net.Conn, req *helper.Request) {
+	response := ""
 
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-		var body struct {
-			Name string `json:"room_name"`
-			Day  int    `json:"day"`
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Fatal(err)
 		}
+		(*conn).Close()
+	}()
 
-		err := json.Unmarshal(reqBodyByte, &body)
+	var body struct {
+		Name string `json:"room_name"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
 				"Parser Error", err.Error())
 
 			return
 		}
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", "name parameter is empty")
 
-		hours, err := models.GetAvailableHours(body.Name, body.Day)
+		return
+	}
 
-		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 404, "Not Found",
-					"Room does not exists", "Room does not exists")
+	weekly, err := models.GetAllAvailableHours(body.Name)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "Room does not exists")
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Validation Error", err.Error())
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
 
-				return
-			}
+			return
 		}
+	}
 
-		hoursStr := strings.Builder{}
-		for _, h := range hours {
-			hoursStr.WriteString(h + " ")
+	hoursStr := strings.Builder{}
+	for _, day := range weekly.Days {
+		hoursStr.WriteString("day " + strconv.Itoa(day.Day) + ": ")
+		if day.Closed {
+			hoursStr.WriteString("closed")
+		}
+		for _, slot := range day.Slots {
+			hoursStr.WriteString(slot.String() + " ")
 		}
 		hoursStr.WriteRune('\n')
-
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			fmt.Sprintf("Available hours for room %s for day %d is listed below",
-				body.Name, body.Day), hoursStr.String())
-
-		return
-
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-			"Method not supported", "Method not supported")
-
-		return
 	}
+	hoursStr.WriteRune('\n')
+
+	response = helper.CreateSuccessResponse(req, "Room",
+		fmt.Sprintf("Available hours for room %s for this week is listed below", body.Name),
+		hoursStr.String(), weekly)
 }
 
-func HandleCheckWeeklyAvailability(conn *net.Conn, req string) {
-	reqParts := strings.Split(req, "\n")
-	method := strings.TrimSpace(strings.Split(reqParts[0], " ")[0])
+// HandleUpdate changes the capacity, building, floor, equipment or opening hours of a room, the
+// ones that are not given are kept.
+func HandleUpdate(conn *net.Conn, req *helper.Request) {
 	response := ""
-
 	defer func() {
 		_, err := (*conn).Write([]byte(response))
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("Error: %+v", err)
 		}
 		(*conn).Close()
 	}()
-	switch method {
+
+	var body struct {
+		Name string `json:"room_name"`
+		models.RoomUpdate
+	}
+	switch req.Method {
 	case "GET":
 		// GET request has been made, read the query params from the URL
-		url := strings.TrimSpace(strings.Split(reqParts[0], " ")[1])
-		// GET request has been made, read the query params from the URL
-		// url will be in the format of .../add?name=roomname so splitting according to the equal sign makes sense
+		body.Name = req.Query.Get("name")
+		err := readRoomAttributes(req, &body.RoomUpdate)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
-		// GET request has been made, read the query params from the URL
-		query := strings.Split(url, "?")[1]
-		params := strings.Split(query, "&")
-		paramsMap := make(map[string]string)
-		for _, param := range params {
-			temp := strings.Split(param, "=")
-			paramsMap[temp[0]] = temp[1]
+			return
 		}
-		// return error if roomname has not given
-		if _, ok := paramsMap["name"]; !ok {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-				"Parser Error", "name parameter is empty")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
+		}
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Empty Parameter", "name parameter is empty")
+
+		return
+	}
+
+	room, err := models.UpdateRoom(body.Name, &body.RoomUpdate)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "There is no room exists with the given name")
+
+			return
+		} else if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name, add it first")
+
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+				"Validation Error", err.Error())
 
 			return
 		}
+	}
 
-		daysandhours, err := models.GetAllAvailableHours(paramsMap["name"])
+	response = helper.CreateSuccessResponse(req, "Room",
+		"Succesfull", fmt.Sprintf("Room %s successfully updated\n%s", room.Name, roomDetails(room)), room)
+}
 
+// readRoomAttributes reads the capacity, building, floor, comma separated equipment and opening
+// hours such as 1-5=08:00-18:00,6=10:00-14:00 of a room from the query params, the ones that are
+// not given are left nil.
+func readRoomAttributes(req *helper.Request, attributes *models.RoomUpdate) error {
+	if req.Query.Has("capacity") {
+		capacity, err := req.IntParam("capacity")
+		if err != nil {
+			return err
+		}
+		attributes.Capacity = &capacity
+	}
+	if req.Query.Has("building") {
+		building := req.Query.Get("building")
+		attributes.Building = &building
+	}
+	if req.Query.Has("floor") {
+		floor, err := req.IntParam("floor")
+		if err != nil {
+			return err
+		}
+		attributes.Floor = &floor
+	}
+	if req.Query.Has("equipment") {
+		equipment := strings.Split(req.Query.Get("equipment"), ",")
+		attributes.Equipment = &equipment
+	}
+	if req.Query.Has("opening_hours") {
+		schedule, err := models.ParseSchedule(req.Query.Get("opening_hours"))
 		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 404, "Not Found",
-					"Room does not exists", "Room does not exists")
+			return errors.New("opening_hours parameter: " + err.Error())
+		}
+		openingHours := []models.DayHours(schedule)
+		attributes.OpeningHours = &openingHours
+	}
+	return nil
+}
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Validation Error", err.Error())
+// dateOrEveryWeek is the date of a reservation or a blackout, one without a date recurs every
+// week on its day.
+func dateOrEveryWeek(date string, day int) string {
+	if date == "" {
+		return "every week on day " + strconv.Itoa(day)
+	}
+	return date
+}
+
+// roomDetails is the human readable line of a room and its attributes for the HTML pages.
+func roomDetails(room *models.Room) string {
+	details := []string{}
+	if room.Capacity > 0 {
+		details = append(details, fmt.Sprintf("capacity %d", room.Capacity))
+	}
+	if room.Building != "" {
+		details = append(details, fmt.Sprintf("building %s floor %d", room.Building, room.Floor))
+	}
+	if len(room.Equipment) > 0 {
+		details = append(details, "equipment: "+room.Equipment.String())
+	}
+	if len(room.OpeningHours) > 0 {
+		details = append(details, "open "+room.OpeningHours.String())
+	}
+	if len(details) == 0 {
+		return room.Name
+	}
+	return fmt.Sprintf("%s (%s)", room.Name, strings.Join(details, ", "))
+}
+
+// alternativeDetails is the human readable listing of what can be reserved instead of a
+// reservation that conflicts for the HTML pages.
+func alternativeDetails(alternatives *models.Alternatives) string {
+	details := strings.Builder{}
+	details.WriteString("Free at another time:\n")
+	if len(alternatives.Slots) == 0 {
+		details.WriteString("None\n")
+	}
+	for _, slot := range alternatives.Slots {
+		when := dateOrEveryWeek(slot.Date, slot.Day)
+		details.WriteString(fmt.Sprintf("%s %s %s-%s\n", slot.RoomName, when, slot.Start, slot.End))
+	}
+	details.WriteString("\nFree at the same time:\n")
+	if len(alternatives.OtherRooms) == 0 {
+		details.WriteString("None\n")
+	}
+	for i := range alternatives.OtherRooms {
+		details.WriteString(roomDetails(&alternatives.OtherRooms[i]) + "\n")
+	}
+	return details.String()
+}
+
+// HandleRooms lists the rooms a page at a time, only the ones whose names contain name if it
+// is given. The next_cursor of a page asks for the one after it.
+func HandleRooms(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
+
+	var body struct {
+		Name   string `json:"room_name"`
+		Cursor string `json:"cursor"`
+		Limit  int    `json:"limit"`
+	}
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+		body.Cursor = req.Query.Get("cursor")
+		if req.Query.Get("limit") != "" {
+			var err error
+			body.Limit, err = req.IntParam("limit")
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+					"Parser Error", err.Error())
 
 				return
 			}
 		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		if len(req.Body) > 0 {
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+					"Parser Error", err.Error())
 
-		hoursStr := strings.Builder{}
-		for day, hours := range daysandhours {
-			hoursStr.WriteString(day + ": ")
-			for _, h := range hours {
-				hoursStr.WriteString(h + " ")
+				return
 			}
-			hoursStr.WriteRune('\n')
 		}
-		hoursStr.WriteRune('\n')
-
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			fmt.Sprintf("Available hours for room %s for this week is listed below",
-				paramsMap["name"]), hoursStr.String())
+	}
+	if body.Limit == 0 {
+		body.Limit = models.DefaultPageSize
+	}
+	if body.Limit < 1 || body.Limit > models.MaxPageSize {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Parser Error", fmt.Sprintf("limit should be between 1 and %d", models.MaxPageSize))
 
 		return
+	}
 
-	case "POST":
-		// POST request has been made, read the request body
+	page, err := models.FindRooms(body.Name, body.Cursor, body.Limit)
+	if err != nil {
+		if err.Error() == "cursor is not one of this listing" {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
+
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
+
+			return
+		}
+	}
+
+	roomsStr := strings.Builder{}
+	if len(page.Rooms) == 0 {
+		roomsStr.WriteString("No rooms found.\n")
+	}
+	for i := range page.Rooms {
+		roomsStr.WriteString(roomDetails(&page.Rooms[i]) + "\n")
+	}
+	if page.NextCursor != "" && req.Method == "GET" {
+		next := req.Query
+		next.Set("cursor", page.NextCursor)
+		roomsStr.WriteString(fmt.Sprintf("\nNext page: %s?%s\n", req.Path, next.Encode()))
+	} else if page.NextCursor != "" {
+		roomsStr.WriteString(fmt.Sprintf("\nNext cursor: %s\n", page.NextCursor))
+	}
+
+	response = helper.CreateSuccessResponse(req, "Room", "Rooms are listed below", roomsStr.String(), page)
+}
 
-		reqBody := strings.Split(req, "{")[1]
-		reqBody = "{" + reqBody
-		reqBodyByte := bytes.Trim([]byte(reqBody), "\x00") //		// unmarshall the json body to a struct
-		var body struct {
-			Name string `json:"room_name"`
+// HandleRoom shows a room along with its reservations.
+func HandleRoom(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
+		if err != nil {
+			log.Printf("Error: %+v", err)
 		}
+		(*conn).Close()
+	}()
 
-		err := json.Unmarshal(reqBodyByte, &body)
+	var body models.Room
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		body.Name = req.Query.Get("name")
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
 		if err != nil {
-			response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
 				"Parser Error", err.Error())
 
 			return
 		}
+	}
+	// return error if roomname has not given
+	if body.Name == "" {
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+			"Empty Parameter", "name parameter is empty")
+
+		return
+	}
+
+	room, err := models.GetRoom(body.Name)
+	if err != nil {
+		if err.Error() == "Room does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrRoomNotFound,
+				"Room does not exists", "Room does not exists")
+
+			return
+		} else {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrDatabase,
+				"Database Error", err.Error())
+
+			return
+		}
+	}
+
+	reservationsStr := strings.Builder{}
+	reservationsStr.WriteString(roomDetails(room) + "\n\n")
+	if len(room.Reservations) == 0 {
+		reservationsStr.WriteString("The room has no reservations.\n")
+	}
+	for _, reservation := range room.Reservations {
+		when := dateOrEveryWeek(reservation.Date, reservation.Day)
+		reservationsStr.WriteString(fmt.Sprintf("Reservation %d: %s %s-%s\n", reservation.ID, when,
+			reservation.Start, reservation.End))
+	}
 
-		daysandhours, err := models.GetAllAvailableHours(body.Name)
+	response = helper.CreateSuccessResponse(req, "Room",
+		fmt.Sprintf("Reservations of room %s are listed below", room.Name), reservationsStr.String(), room)
+}
 
+// HandleSearch finds the rooms that fit the capacity and have the equipment asked for, and are
+// free for the whole time window. The best fitting rooms are listed first.
+func HandleSearch(conn *net.Conn, req *helper.Request) {
+	response := ""
+	defer func() {
+		_, err := (*conn).Write([]byte(response))
 		if err != nil {
-			if err.Error() == "Room does not exists" {
-				response = helper.CreateHTTPResponse("Room", 404, "Not Found",
-					"Room does not exists", "Room does not exists")
+			log.Printf("Error: %+v", err)
+		}
+		(*conn).Close()
+	}()
 
-				return
-			} else {
-				response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-					"Validation Error", err.Error())
+	var body models.RoomSearch
+	switch req.Method {
+	case "GET":
+		// GET request has been made, read the query params from the URL
+		var err error
+		body, err = readRoomSearch(req)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidParameter,
+				"Parser Error", err.Error())
 
-				return
-			}
+			return
+		}
+	case "POST":
+		// POST request has been made, unmarshall the json body to a struct
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrInvalidBody,
+				"Parser Error", err.Error())
+
+			return
 		}
+	}
 
-		hoursStr := strings.Builder{}
-		for day, hours := range daysandhours {
-			hoursStr.WriteString(day + ": ")
-			for _, h := range hours {
-				hoursStr.WriteString(h + " ")
-			}
-			hoursStr.WriteRune('\n')
+	result, err := models.SearchRooms(&body)
+	if err != nil {
+		if err.Error() == "Building does not exists" {
+			response = helper.CreateErrorResponse(req, "Room", 404, "Not Found", helper.ErrBuildingNotFound,
+				"Building does not exists", "There is no building exists with the given name")
+
+			return
 		}
-		hoursStr.WriteRune('\n')
-		response = helper.CreateHTTPResponse("Room", 200, "OK",
-			fmt.Sprintf("Available hours for room %s for this week is listed below",
-				body.Name), hoursStr.String())
+		response = helper.CreateErrorResponse(req, "Room", 400, "Bad Request", helper.ErrValidation,
+			"Validation Error", err.Error())
 
 		return
+	}
 
-	default:
-		// unknown http method has been used, throw error
-		response = helper.CreateHTTPResponse("Room", 400, "Bad Request",
-			"Method not supported", "Method not supported")
+	roomsStr := strings.Builder{}
+	if len(result.Rooms) == 0 {
+		roomsStr.WriteString("No rooms found.\n")
+	}
+	for i := range result.Rooms {
+		roomsStr.WriteString(roomDetails(&result.Rooms[i]) + "\n")
+	}
 
-		return
+	when := dateOrEveryWeek(result.Date, result.Day)
+	response = helper.CreateSuccessResponse(req, "Room",
+		fmt.Sprintf("Rooms free on %s from %s to %s are listed below", when, result.Start, result.End),
+		roomsStr.String(), result)
+}
+
+// readRoomSearch reads the time window of a search like the one of a reservation, along with
+// the capacity and the comma separated equipment it asks for, and the building and floor to
+// search in.
+func readRoomSearch(req *helper.Request) (models.RoomSearch, error) {
+	search := models.RoomSearch{}
+	window := models.Reservation{}
+
+	// a date searches that date only, a day of the week searches it every week
+	var err error
+	window.Date = req.Query.Get("date")
+	if window.Date == "" {
+		window.Day, err = req.IntParam("day")
+	}
+	if err == nil {
+		err = readTimeParams(req, &window)
 	}
+	if err == nil && req.Query.Get("capacity") != "" {
+		search.Capacity, err = req.IntParam("capacity")
+	}
+	if err == nil && req.Query.Get("floor") != "" {
+		var floor int
+		floor, err = req.IntParam("floor")
+		search.Floor = &floor
+	}
+	if err != nil {
+		return search, err
+	}
+	search.Building = req.Query.Get("building")
+	if req.Query.Get("equipment") != "" {
+		search.Equipment = strings.Split(req.Query.Get("equipment"), ",")
+	}
+
+	search.Date = window.Date
+	search.Day = window.Day
+	search.Start = window.Start
+	search.End = window.End
+	search.Hour = window.Hour
+	search.Duration = window.Duration
+	return search, nil
 }
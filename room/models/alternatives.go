@@ -0,0 +1,165 @@
+package models
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/gorm"
+)
+
+// MaxAlternatives bounds how many slots and how many rooms are offered instead of a
+// reservation that conflicts.
+const MaxAlternatives = 3
+
+// alternativeDays is how many days after the one asked for are looked at for free slots.
+const alternativeDays = 7
+
+// Alternatives are what can be reserved instead of a reservation that conflicts. Slots are
+// the nearest free times of the same length in the same room, those on the same date or day
+// closest to the time asked for first, then the earliest ones of the following days. OtherRooms
+// are the rooms free at the same time, the ones with the capacity and the equipment of the
+// room asked for first.
+type Alternatives struct {
+	Slots      []Reservation `json:"slots"`
+	OtherRooms []Room        `json:"other_rooms"`
+}
+
+// FindAlternatives returns the alternatives of a reservation whose time has already been
+// validated, such as one CreateReservation refused with "Already Reserved" or "Room is
+// blacked out".
+func FindAlternatives(reservation *Reservation) (*Alternatives, error) {
+	alternatives := &Alternatives{Slots: []Reservation{}, OtherRooms: []Room{}}
+	length := int(reservation.End - reservation.Start)
+	room, err := findRoom(reservation.RoomName)
+	if err != nil {
+		return nil, err
+	}
+
+	// the same date or day, closest to the time asked for first
+	slots, err := freeSlots(room, reservation.Date, reservation.Day, length)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return distance(slots[i], reservation.Start) < distance(slots[j], reservation.Start)
+	})
+	for _, start := range slots {
+		alternatives.addSlot(reservation, reservation.Date, reservation.Day, start, length)
+	}
+
+	// then the following dates, or the following days of the week for a weekly reservation
+	var date time.Time
+	if reservation.Date != "" {
+		date, err = ParseDate(reservation.Date)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := 1; i <= alternativeDays && len(alternatives.Slots) < MaxAlternatives; i++ {
+		nextDate := ""
+		nextDay := (reservation.Day+i-1)%7 + 1
+		if reservation.Date != "" {
+			nextDate = date.AddDate(0, 0, i).Format(DateLayout)
+		}
+		slots, err := freeSlots(room, nextDate, nextDay, length)
+		if err != nil {
+			return nil, err
+		}
+		for _, start := range slots {
+			alternatives.addSlot(reservation, nextDate, nextDay, start, length)
+		}
+	}
+
+	// the other rooms free at the same time
+	result, err := SearchRooms(&RoomSearch{
+		Date:  reservation.Date,
+		Day:   reservation.Day,
+		Start: reservation.Start,
+		End:   reservation.End,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rooms := []Room{}
+	for _, other := range result.Rooms {
+		// the rows left without a name by the old versions cannot be reserved
+		if other.Name != reservation.RoomName && other.Name != "" {
+			rooms = append(rooms, other)
+		}
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		return room.replacedBy(&rooms[i]) && !room.replacedBy(&rooms[j])
+	})
+	if len(rooms) > MaxAlternatives {
+		rooms = rooms[:MaxAlternatives]
+	}
+	alternatives.OtherRooms = rooms
+
+	return alternatives, nil
+}
+
+// addSlot offers the time starting at start on the date or day in the room of the reservation,
+// unless there are enough slots already.
+func (alternatives *Alternatives) addSlot(reservation *Reservation, date string, day int, start Clock, length int) {
+	if len(alternatives.Slots) >= MaxAlternatives {
+		return
+	}
+	alternatives.Slots = append(alternatives.Slots, Reservation{
+		RoomName: reservation.RoomName,
+		Date:     date,
+		Day:      day,
+		Start:    start,
+		End:      start + Clock(length),
+	})
+}
+
+// freeSlots lists the times a reservation of the given length can start at in the room on the
+// date, or on the day every week from now on when there is no date, in order.
+func freeSlots(room *Room, date string, day int, length int) ([]Clock, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("room_name = ? AND day = ?", room.Name, day).Scopes(sharingDates(date))
+	}
+	reservations := []Reservation{}
+	err := database.DBConn.Scopes(scope).Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	reservations, err = withBlackouts(reservations, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []Clock{}
+	for _, free := range dayAvailability(room, day, reservations).Free {
+		start := (int(free.Start) + SlotMinutes - 1) / SlotMinutes * SlotMinutes
+		for t := start; Clock(t+length) <= free.End; t += SlotMinutes {
+			slots = append(slots, Clock(t))
+		}
+	}
+	return slots, nil
+}
+
+// distance is how many minutes apart the clocks are.
+func distance(a Clock, b Clock) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// replacedBy reports whether the other room fits as many people as the room and has all of its equipment.
+func (room *Room) replacedBy(other *Room) bool {
+	if other.Capacity < room.Capacity {
+		return false
+	}
+	for _, tag := range room.Equipment {
+		if !other.Equipment.Has(tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/gorm"
+)
+
+// Blackout is a time a room cannot be reserved in, such as for cleaning, renovation or exams,
+// along with the Reason why. Like a reservation, a blackout with a Date is for that date only
+// and one without a Date recurs every week on its Day. It takes the whole day when neither its
+// Start nor its End is given.
+type Blackout struct {
+	ID       uint   `gorm:"primaryKey:auto_increment" json:"id"`
+	RoomName string `gorm:"index" json:"room_name"`
+	Date     string `gorm:"not null;default:''" json:"date,omitempty"`
+	Day      int    `json:"day"`
+	Start    Clock  `gorm:"column:starts_at;not null;default:0" json:"start"`
+	End      Clock  `gorm:"column:ends_at;not null;default:0" json:"end"`
+	Reason   string `gorm:"not null;default:''" json:"reason"`
+}
+
+// Interval is the time range the blackout takes on its day.
+func (blackout *Blackout) Interval() Interval {
+	return Interval{Start: blackout.Start, End: blackout.End}
+}
+
+// Validate checks the room, the date or day and the time of the blackout, and fills the ones
+// that can be worked out from the others.
+func (blackout *Blackout) Validate() error {
+	err := checkRoomExists(blackout.RoomName)
+	if err != nil {
+		return err
+	}
+
+	blackout.Reason = strings.TrimSpace(blackout.Reason)
+	if blackout.Reason == "" {
+		return errors.New("reason of blackout cannot be empty")
+	}
+
+	day, err := dayOfDate(blackout.Date, blackout.Day, "blackout")
+	if err != nil {
+		return err
+	}
+	blackout.Day = day
+
+	// the whole day
+	if blackout.Start == 0 && blackout.End == 0 {
+		blackout.End = EndOfDay
+	}
+	if blackout.End <= blackout.Start {
+		return errors.New("end of blackout should be after its start")
+	}
+	if blackout.End > EndOfDay {
+		return errors.New("end of blackout cannot be after 24:00")
+	}
+	return nil
+}
+
+// CreateBlackout blacks out the time of the room. The time should be free, when some reservations
+// share time with it they are returned along with the "Already Reserved" error, they should be
+// cancelled or moved first.
+func CreateBlackout(blackout *Blackout) ([]Reservation, error) {
+	err := blackout.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	overlapping := []Reservation{}
+	err = database.DBConn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("room_name = ? AND day = ? AND starts_at < ? AND ends_at > ?",
+			blackout.RoomName, blackout.Day, blackout.End, blackout.Start).
+			Scopes(sharingDates(blackout.Date)).Order("date, starts_at").Find(&overlapping).Error
+		if err != nil {
+			return err
+		}
+		if len(overlapping) > 0 {
+			return errors.New("Already Reserved")
+		}
+
+		return tx.Create(blackout).Error
+	})
+	if err != nil {
+		if err.Error() == "Already Reserved" {
+			return overlapping, err
+		}
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return nil, nil
+}
+
+// GetBlackouts returns the blackouts of the room from now on, or of every room when no room
+// is given, ordered by room, date and time. The weekly ones come before the dated ones.
+func GetBlackouts(roomname string) ([]Blackout, error) {
+	db := database.DBConn.Scopes(sharingDates(""))
+	if roomname != "" {
+		err := checkRoomExists(roomname)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("room_name = ?", roomname)
+	}
+
+	blackouts := []Blackout{}
+	err := db.Order("room_name, date, day, starts_at").Find(&blackouts).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return blackouts, nil
+}
+
+// RemoveBlackout permanently removes the blackout with the given id.
+func RemoveBlackout(id uint) (*Blackout, error) {
+	blackout := &Blackout{}
+	err := database.DBConn.Where("id = ?", id).Limit(1).Find(blackout).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if blackout.ID == 0 {
+		return nil, errors.New("Blackout does not exists")
+	}
+
+	err = database.DBConn.Delete(&Blackout{}, id).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return blackout, nil
+}
+
+// findBlackout returns a blackout of the room that shares time with the reservation, or nil
+// when there is none.
+func findBlackout(tx *gorm.DB, reservation *Reservation) (*Blackout, error) {
+	found := []Blackout{}
+	err := tx.Where("room_name = ? AND day = ? AND starts_at < ? AND ends_at > ?",
+		reservation.RoomName, reservation.Day, reservation.End, reservation.Start).
+		Scopes(sharingDates(reservation.Date)).Limit(1).Find(&found).Error
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return &found[0], nil
+}
+
+// withBlackouts adds the blackouts the scope selects to the reservations, so that they count
+// as busy time. The scope should select them by the columns both tables have.
+func withBlackouts(reservations []Reservation, scope func(*gorm.DB) *gorm.DB) ([]Reservation, error) {
+	blackouts := []Blackout{}
+	err := database.DBConn.Scopes(scope).Find(&blackouts).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	for _, blackout := range blackouts {
+		reservations = append(reservations, Reservation{
+			RoomName: blackout.RoomName,
+			Date:     blackout.Date,
+			Day:      blackout.Day,
+			Start:    blackout.Start,
+			End:      blackout.End,
+		})
+	}
+	return reservations, nil
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/gorm"
+)
+
+// Building groups rooms, a room is in a building when its Building is the building's Name and
+// on the floor its Floor says. The rooms without a building are not in any of them.
+type Building struct {
+	Name        string `gorm:"primaryKey" json:"building_name"`
+	Description string `gorm:"not null;default:''" json:"description"`
+}
+
+// Floor is the rooms on a floor of a building, ordered by their names.
+type Floor struct {
+	Floor int    `json:"floor"`
+	Rooms []Room `json:"rooms"`
+}
+
+// BuildingFloors is a building along with its rooms floor by floor, the lowest floor first.
+type BuildingFloors struct {
+	Building
+	Floors []Floor `json:"floors"`
+}
+
+// CreateBuilding saves a building, its name should not be taken.
+func CreateBuilding(building *Building) error {
+	building.Name = strings.TrimSpace(building.Name)
+	building.Description = strings.TrimSpace(building.Description)
+	if building.Name == "" {
+		return errors.New("building name cannot be empty")
+	}
+
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		exists, err := buildingExists(tx, building.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errors.New("Building already exists")
+		}
+		return tx.Create(building).Error
+	})
+	if err != nil && err.Error() != "Building already exists" {
+		log.Printf("Error: %+v", err)
+	}
+	return err
+}
+
+// GetBuildings returns every building ordered by name.
+func GetBuildings() ([]Building, error) {
+	buildings := []Building{}
+	err := database.DBConn.Order("name").Find(&buildings).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return buildings, nil
+}
+
+// GetBuilding returns the building with the given name along with its rooms floor by floor.
+func GetBuilding(name string) (*BuildingFloors, error) {
+	buildings := []Building{}
+	err := database.DBConn.Where("name = ?", name).Limit(1).Find(&buildings).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if len(buildings) == 0 {
+		return nil, errors.New("Building does not exists")
+	}
+
+	rooms := []Room{}
+	err = database.DBConn.Where("building = ?", name).Order("floor, name").Find(&rooms).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return &BuildingFloors{Building: buildings[0], Floors: byFloor(rooms)}, nil
+}
+
+// RemoveBuilding permanently removes a building, its rooms should be removed or moved first.
+func RemoveBuilding(name string) error {
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		exists, err := buildingExists(tx, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("Building does not exists")
+		}
+
+		var rooms int64
+		err = tx.Model(Room{}).Where("building = ?", name).Count(&rooms).Error
+		if err != nil {
+			return err
+		}
+		if rooms > 0 {
+			return errors.New("Building has rooms")
+		}
+		return tx.Delete(&Building{Name: name}).Error
+	})
+	if err != nil && err.Error() != "Building does not exists" && err.Error() != "Building has rooms" {
+		log.Printf("Error: %+v", err)
+	}
+	return err
+}
+
+// GetReservationsByBuilding returns the rooms that have reservations taking place on the date,
+// or from now on when there is no date, with those reservations grouped by building and floor.
+// Only the building with the given name is listed when there is one, otherwise every building
+// in the order of their names, followed by the rooms without a building under an empty name.
+func GetReservationsByBuilding(building string, date string) ([]BuildingFloors, error) {
+	buildings := []Building{}
+	db := database.DBConn.Order("name")
+	if building != "" {
+		db = db.Where("name = ?", building)
+	}
+	err := db.Find(&buildings).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if building != "" && len(buildings) == 0 {
+		return nil, errors.New("Building does not exists")
+	}
+
+	taking := func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(sharingDates(date)).Order("date, day, starts_at")
+	}
+	if date != "" {
+		t, err := ParseDate(date)
+		if err != nil {
+			return nil, err
+		}
+		day := Weekday(t)
+		taking = func(db *gorm.DB) *gorm.DB {
+			return db.Where("day = ?", day).Scopes(sharingDates(date)).Order("starts_at")
+		}
+	}
+
+	rooms := []Room{}
+	db = database.DBConn.Preload("Reservations", taking).Order("building = '', building, floor, name")
+	if building != "" {
+		db = db.Where("building = ?", building)
+	}
+	err = db.Find(&rooms).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+
+	reserved := map[string][]Room{}
+	for _, room := range rooms {
+		if len(room.Reservations) > 0 {
+			reserved[room.Building] = append(reserved[room.Building], room)
+		}
+	}
+	if building == "" && len(reserved[""]) > 0 {
+		buildings = append(buildings, Building{})
+	}
+
+	grouped := []BuildingFloors{}
+	for _, b := range buildings {
+		if len(reserved[b.Name]) > 0 {
+			grouped = append(grouped, BuildingFloors{Building: b, Floors: byFloor(reserved[b.Name])})
+		}
+	}
+	return grouped, nil
+}
+
+// byFloor groups the rooms ordered by floor into floors.
+func byFloor(rooms []Room) []Floor {
+	floors := []Floor{}
+	for _, room := range rooms {
+		last := len(floors) - 1
+		if last < 0 || floors[last].Floor != room.Floor {
+			floors = append(floors, Floor{Floor: room.Floor})
+			last++
+		}
+		floors[last].Rooms = append(floors[last].Rooms, room)
+	}
+	return floors
+}
+
+// buildingExists reports whether there is a building with the given name.
+func buildingExists(tx *gorm.DB, name string) (bool, error) {
+	var exists bool
+	err := tx.Model(Building{}).Select("count(*) > 0").Where("name = ?", name).Find(&exists).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+	}
+	return exists, err
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the ISO 8601 calendar date format reservations and queries use.
+const DateLayout = "2006-01-02"
+
+// MaxRangeDays bounds the date ranges availability can be queried for.
+const MaxRangeDays = 62
+
+// ParseDate parses an ISO 8601 calendar date such as 2022-11-25.
+func ParseDate(date string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return time.Time{}, errors.New("date should be in YYYY-MM-DD format")
+	}
+	return t, nil
+}
+
+// Weekday returns the day of the week of date the way reservations count them,
+// 1 for Monday through 7 for Sunday.
+func Weekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+// Today is the current local date.
+func Today() string {
+	return time.Now().Format(DateLayout)
+}
+
+// dayOfDate checks that the date of a reservation or a blackout, the what, is not in the past
+// and falls on its day of the week if that is given, then returns the day of the week of the
+// date. Without a date the day is returned as it is.
+func dayOfDate(date string, day int, what string) (int, error) {
+	if date != "" {
+		t, err := ParseDate(date)
+		if err != nil {
+			return 0, err
+		}
+		if date < Today() {
+			return 0, fmt.Errorf("date of %s cannot be in the past", what)
+		}
+		if day != 0 && day != Weekday(t) {
+			return 0, fmt.Errorf("day value of %s does not match its date", what)
+		}
+		day = Weekday(t)
+	}
+
+	if day < 1 || day > 7 {
+		return 0, fmt.Errorf("day value of %s should be 1 to 7", what)
+	}
+	return day, nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import "sort"
+
+// Interval is the half-open time range [Start, End) of a day.
+type Interval struct {
+	Start Clock `json:"start"`
+	End   Clock `json:"end"`
+}
+
+// DefaultOpeningHours is the part of every day that the rooms without a schedule of their own
+// can be reserved in.
+var DefaultOpeningHours = Interval{Start: ClockOf(9, 0), End: ClockOf(17, 0)}
+
+// Empty reports whether the interval contains no time at all.
+func (i Interval) Empty() bool {
+	return i.End <= i.Start
+}
+
+// Overlaps reports whether the intervals share any time, touching ends do not overlap.
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Start < other.End && other.Start < i.End
+}
+
+// Contains reports whether other lies completely within the interval.
+func (i Interval) Contains(other Interval) bool {
+	return i.Start <= other.Start && other.End <= i.End
+}
+
+// Merge sorts the intervals and joins the overlapping and adjacent ones, empty intervals are dropped.
+func Merge(intervals []Interval) []Interval {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, i := range intervals {
+		if !i.Empty() {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Start < sorted[b].Start
+	})
+
+	merged := []Interval{}
+	for _, i := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && i.Start <= merged[last].End {
+			if i.End > merged[last].End {
+				merged[last].End = i.End
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// Subtract returns the parts of window that are not covered by any of the busy intervals.
+func Subtract(window Interval, busy []Interval) []Interval {
+	free := []Interval{}
+	start := window.Start
+	for _, b := range Merge(busy) {
+		if b.End <= start {
+			continue
+		}
+		if b.Start >= window.End {
+			break
+		}
+		if b.Start > start {
+			free = append(free, Interval{Start: start, End: b.Start})
+		}
+		start = b.End
+	}
+	if start < window.End {
+		free = append(free, Interval{Start: start, End: window.End})
+	}
+	return free
+}
+
+// Slots lists the start of every slot of the given length within the intervals, slots are
+// aligned to multiples of their length.
+func Slots(intervals []Interval, minutes int) []Clock {
+	slots := []Clock{}
+	for _, i := range intervals {
+		start := (int(i.Start) + minutes - 1) / minutes * minutes
+		for t := start; Clock(t+minutes) <= i.End; t += minutes {
+			slots = append(slots, Clock(t))
+		}
+	}
+	return slots
+}
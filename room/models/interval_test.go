@@ -0,0 +1,111 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// span is the interval from the hour and minute of start to the ones of end.
+func span(startHour, startMinute, endHour, endMinute int) Interval {
+	return Interval{Start: ClockOf(startHour, startMinute), End: ClockOf(endHour, endMinute)}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []Interval
+		want      []Interval
+	}{
+		{"nil input", nil, []Interval{}},
+		{"empty input", []Interval{}, []Interval{}},
+		{"empty intervals are dropped", []Interval{span(10, 0, 10, 0), span(12, 0, 11, 0)}, []Interval{}},
+		{"single", []Interval{span(9, 0, 10, 0)}, []Interval{span(9, 0, 10, 0)}},
+		{"disjoint stay apart", []Interval{span(9, 0, 10, 0), span(11, 0, 12, 0)},
+			[]Interval{span(9, 0, 10, 0), span(11, 0, 12, 0)}},
+		{"adjacent are joined", []Interval{span(9, 0, 10, 0), span(10, 0, 11, 0)}, []Interval{span(9, 0, 11, 0)}},
+		{"overlapping are joined", []Interval{span(9, 0, 10, 30), span(10, 0, 11, 0)}, []Interval{span(9, 0, 11, 0)}},
+		{"contained is absorbed", []Interval{span(9, 0, 12, 0), span(10, 0, 11, 0)}, []Interval{span(9, 0, 12, 0)}},
+		{"unsorted input is sorted", []Interval{span(14, 0, 15, 0), span(9, 0, 10, 0), span(9, 30, 10, 15)},
+			[]Interval{span(9, 0, 10, 15), span(14, 0, 15, 0)}},
+		{"chain of adjacent and overlapping", []Interval{span(9, 0, 10, 0), span(10, 0, 11, 0), span(10, 30, 12, 0)},
+			[]Interval{span(9, 0, 12, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge(tt.intervals)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge(%v) = %v, want %v", tt.intervals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	window := span(9, 0, 17, 0)
+	tests := []struct {
+		name string
+		busy []Interval
+		want []Interval
+	}{
+		{"nothing busy", nil, []Interval{window}},
+		{"whole window busy", []Interval{window}, []Interval{}},
+		{"window contained in busy", []Interval{span(8, 0, 18, 0)}, []Interval{}},
+		{"busy contained in window", []Interval{span(12, 0, 13, 0)}, []Interval{span(9, 0, 12, 0), span(13, 0, 17, 0)}},
+		{"busy at the start", []Interval{span(9, 0, 10, 0)}, []Interval{span(10, 0, 17, 0)}},
+		{"busy at the end", []Interval{span(16, 0, 17, 0)}, []Interval{span(9, 0, 16, 0)}},
+		{"busy across the edges", []Interval{span(8, 0, 10, 0), span(16, 30, 18, 0)}, []Interval{span(10, 0, 16, 30)}},
+		{"busy outside the window", []Interval{span(7, 0, 9, 0), span(17, 0, 19, 0)}, []Interval{window}},
+		{"adjacent busy leave no gap", []Interval{span(10, 0, 11, 0), span(11, 0, 12, 0)},
+			[]Interval{span(9, 0, 10, 0), span(12, 0, 17, 0)}},
+		{"overlapping busy", []Interval{span(10, 0, 11, 30), span(11, 0, 12, 0)},
+			[]Interval{span(9, 0, 10, 0), span(12, 0, 17, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Subtract(window, tt.busy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subtract(%v, %v) = %v, want %v", window, tt.busy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlots(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []Interval
+		minutes   int
+		want      []Clock
+	}{
+		{"no intervals 15", nil, 15, []Clock{}},
+		{"aligned 15", []Interval{span(9, 0, 10, 0)}, 15,
+			[]Clock{ClockOf(9, 0), ClockOf(9, 15), ClockOf(9, 30), ClockOf(9, 45)}},
+		{"unaligned start 15", []Interval{span(9, 10, 10, 0)}, 15,
+			[]Clock{ClockOf(9, 15), ClockOf(9, 30), ClockOf(9, 45)}},
+		{"unaligned end 15", []Interval{span(9, 0, 9, 40)}, 15, []Clock{ClockOf(9, 0), ClockOf(9, 15)}},
+		{"too short 15", []Interval{span(9, 5, 9, 25)}, 15, []Clock{}},
+
+		{"no intervals 30", []Interval{}, 30, []Clock{}},
+		{"aligned 30", []Interval{span(9, 0, 10, 30)}, 30, []Clock{ClockOf(9, 0), ClockOf(9, 30), ClockOf(10, 0)}},
+		{"unaligned start 30", []Interval{span(9, 15, 10, 30)}, 30, []Clock{ClockOf(9, 30), ClockOf(10, 0)}},
+		{"unaligned end 30", []Interval{span(9, 0, 10, 15)}, 30, []Clock{ClockOf(9, 0), ClockOf(9, 30)}},
+		{"too short 30", []Interval{span(9, 15, 9, 45)}, 30, []Clock{}},
+
+		{"no intervals 60", nil, 60, []Clock{}},
+		{"aligned 60", []Interval{span(9, 0, 12, 0)}, 60, []Clock{ClockOf(9, 0), ClockOf(10, 0), ClockOf(11, 0)}},
+		{"unaligned start 60", []Interval{span(9, 30, 12, 0)}, 60, []Clock{ClockOf(10, 0), ClockOf(11, 0)}},
+		{"unaligned end 60", []Interval{span(9, 0, 11, 30)}, 60, []Clock{ClockOf(9, 0), ClockOf(10, 0)}},
+		{"too short 60", []Interval{span(9, 30, 10, 30)}, 60, []Clock{}},
+		{"several intervals 60", []Interval{span(9, 0, 10, 0), span(13, 0, 15, 0)}, 60,
+			[]Clock{ClockOf(9, 0), ClockOf(13, 0), ClockOf(14, 0)}},
+		{"end of day 60", []Interval{span(22, 0, 24, 0)}, 60, []Clock{ClockOf(22, 0), ClockOf(23, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slots(tt.intervals, tt.minutes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Slots(%v, %d) = %v, want %v", tt.intervals, tt.minutes, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,9 +6,26 @@ import (
 )
 
 func init() {
-	err := database.DBConn.AutoMigrate(&Room{}, &Reservation{})
+	// reservations made before dates were introduced get an empty date, so they recur weekly
+	err := database.DBConn.AutoMigrate(&Room{}, &Reservation{}, &Blackout{}, &Building{})
 	if err != nil {
 		log.Fatalf("Cannot migrate models: %s", err.Error())
 	}
 
+	// reservations made before minutes were introduced have their time in whole hours
+	if database.DBConn.Migrator().HasColumn(&Reservation{}, "hour") {
+		err = database.DBConn.Exec("UPDATE reservations SET starts_at = hour * 60, ends_at = (hour + duration) * 60 " +
+			"WHERE ends_at = 0 AND hour IS NOT NULL AND duration IS NOT NULL").Error
+		if err != nil {
+			log.Fatalf("Cannot migrate reservations to minutes: %s", err.Error())
+		}
+	}
+
+	// rooms given a building before buildings were introduced get that building created
+	err = database.DBConn.Exec("INSERT INTO buildings (name) SELECT DISTINCT building FROM rooms " +
+		"WHERE building <> '' AND building NOT IN (SELECT name FROM buildings)").Error
+	if err != nil {
+		log.Fatalf("Cannot migrate buildings of rooms: %s", err.Error())
+	}
+
 }
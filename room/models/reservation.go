@@ -2,45 +2,136 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"log"
-	"strconv"
+	"sync"
 
 	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/gorm"
 )
 
+// reservationLock serializes the conflict check and the insertion of reservations,
+// every connection is served in its own goroutine.
+var reservationLock sync.Mutex
+
 // I have use an external packet just for validating the structs (will acts as table entries)
+// A reservation with a Date is made for that date only, its Day is the day of the week of
+// the date. A reservation without a Date recurs every week on its Day, the reservations
+// made before dates were introduced are all like that.
+// The time of a reservation is from its Start to its End, older clients may give it in whole
+// hours with Hour and Duration instead.
+// Reference is set by the server that made the reservation to find it again, even when it
+// never got the answer holding the ID.
 type Reservation struct {
-	ID       uint   `gorm:"primaryKey:auto_increment" json:"id"`
-	RoomName string `json:"room_name"`
-	Day      int    `json:"day"`
-	Hour     int    `json:"hour"`
-	Duration int    `json:"duration"`
+	ID        uint   `gorm:"primaryKey:auto_increment" json:"id"`
+	RoomName  string `json:"room_name"`
+	Date      string `gorm:"not null;default:''" json:"date,omitempty"`
+	Day       int    `json:"day"`
+	Start     Clock  `gorm:"column:starts_at;not null;default:0" json:"start"`
+	End       Clock  `gorm:"column:ends_at;not null;default:0" json:"end"`
+	Hour      int    `gorm:"-" json:"hour,omitempty"`
+	Duration  int    `gorm:"-" json:"duration,omitempty"`
+	Reference string `gorm:"not null;default:'';index" json:"reference,omitempty"`
+}
+
+// Release selects the reservations to remove at once by their IDs, by their References, or
+// for the Reservations whose ids are not known by their room, date, day, start and end.
+type Release struct {
+	IDs          []uint        `json:"ids"`
+	References   []string      `json:"references,omitempty"`
+	Reservations []Reservation `json:"reservations,omitempty"`
 }
 
+// DayAvailability lists the free time ranges of a room on a date or on a day of the week,
+// and the times a reservation of a single slot can start at. Open is the opening hours of
+// the room that day, there are none when the room is Closed.
+type DayAvailability struct {
+	RoomName string     `json:"room_name"`
+	Date     string     `json:"date,omitempty"`
+	Day      int        `json:"day"`
+	Open     *Interval  `json:"open,omitempty"`
+	Closed   bool       `json:"closed,omitempty"`
+	Free     []Interval `json:"free"`
+	Slots    []Clock    `json:"slots"`
+}
+
+// WeeklyAvailability lists the free hours of a room for every day of the week.
+type WeeklyAvailability struct {
+	RoomName string            `json:"room_name"`
+	Days     []DayAvailability `json:"days"`
+}
+
+// RangeAvailability lists the free hours of a room for every date of a date range.
+type RangeAvailability struct {
+	RoomName string            `json:"room_name"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Days     []DayAvailability `json:"days"`
+}
+
+// Interval is the time range the reservation occupies on its day.
+func (reservation *Reservation) Interval() Interval {
+	return Interval{Start: reservation.Start, End: reservation.End}
+}
 
 func (reservation *Reservation) Validate() error {
 
 	// check if room exists in database
-	var exists bool
-	err := database.DBConn.Model(Room{}).Select("count(*) > 0").Where("name = ?", reservation.RoomName).Find(&exists).Error
+	room, err := findRoom(reservation.RoomName)
 	if err != nil {
-		log.Printf("Error: %+v", err)
 		return err
 	}
-	if !exists {
-		return errors.New("Room does not exists")
+
+	err = reservation.validateTime()
+	if err != nil {
+		return err
+	}
+
+	// the room should be open for the whole reservation
+	hours, open := room.OpeningHours.Hours(reservation.Day)
+	if !open {
+		return fmt.Errorf("Invalid reservation time slice, room %s is closed on day %d", room.Name, reservation.Day)
+	}
+	if !hours.Contains(reservation.Interval()) {
+		return fmt.Errorf("Invalid reservation time slice, room %s is open from %s to %s on day %d",
+			room.Name, hours.Start, hours.End, reservation.Day)
+	}
+	return nil
+}
+
+// validateTime checks the date or day and the time of the reservation, and fills the ones
+// that can be worked out from the others. Whether the room is open then is up to the caller.
+func (reservation *Reservation) validateTime() error {
+	day, err := dayOfDate(reservation.Date, reservation.Day, "reservation")
+	if err != nil {
+		return err
 	}
+	reservation.Day = day
 
-	if reservation.Day < 1 || reservation.Day > 7 {
-		return errors.New("day value of reservation should be 1 to 7")
+	// the time given in whole hours
+	if reservation.Start == 0 && reservation.End == 0 {
+		if reservation.Hour < 0 || reservation.Hour > 23 {
+			return errors.New("hour value of reservation should be 0 to 23")
+		}
+		if reservation.Duration < 1 {
+			return errors.New("duration value of reservation should be at least 1")
+		}
+		reservation.Start = ClockOf(reservation.Hour, 0)
+		reservation.End = ClockOf(reservation.Hour+reservation.Duration, 0)
 	}
+	reservation.Hour = 0
+	reservation.Duration = 0
 
-	if reservation.Hour < 9 || reservation.Hour > 17 {
-		return errors.New("hour value of reservation should be 9 to 17")
+	if reservation.End <= reservation.Start {
+		return errors.New("end of reservation should be after its start")
+	}
+	if reservation.End > EndOfDay {
+		return errors.New("end of reservation cannot be after 24:00")
 	}
 
-	if reservation.Hour+reservation.Duration > 17 {
-		return errors.New("Invalid reservation time slice")
+	if int(reservation.Start)%SlotMinutes != 0 || int(reservation.End)%SlotMinutes != 0 {
+		return fmt.Errorf("start and end of reservation should be multiples of %d minutes", SlotMinutes)
 	}
 	return nil
 }
@@ -54,27 +145,264 @@ func CreateReservation(reservation *Reservation) error {
 		return err
 	}
 
-	// now check if this reservation already made in db
-	hours, err := GetAvailableHours(reservation.RoomName, reservation.Day)
-	if err != nil {
-		return errors.New("Could not get available hours: " + err.Error())
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	// check for overlapping reservations and insert in the same transaction, so no
+	// other writer can book the time slice in between
+	err = database.DBConn.Transaction(func(tx *gorm.DB) error {
+		blackout, err := findBlackout(tx, reservation)
+		if err != nil {
+			return err
+		}
+		if blackout != nil {
+			return errors.New("Room is blacked out")
+		}
+
+		overlaps, err := countOverlaps(tx, reservation)
+		if err != nil {
+			return err
+		}
+		if overlaps > 0 {
+			return errors.New("Already Reserved")
+		}
+
+		return tx.Create(reservation).Error
+	})
+	if err != nil && err.Error() != "Already Reserved" && err.Error() != "Room is blacked out" {
+		log.Printf("Error: %+v", err)
 	}
-	for i := reservation.Hour; i < reservation.Hour+reservation.Duration; i++ {
-		if !contains(hours, i) {
+
+	return err
+}
+
+// Conflict is a reservation of a batch that cannot be made, along with the reason.
+type Conflict struct {
+	Index       int         `json:"index"`
+	Reservation Reservation `json:"reservation"`
+	Reason      string      `json:"reason"`
+}
+
+// CreateReservations makes either all of the reservations or none of them. When some of
+// them overlap the existing reservations or each other, it returns them as conflicts along
+// with the "Already Reserved" error.
+func CreateReservations(reservations []Reservation) ([]Conflict, error) {
+	for i := range reservations {
+		err := reservations[i].Validate()
+		if err != nil {
+			if err.Error() == "Room does not exists" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("reservation %d: %s", i, err.Error())
+		}
+	}
+
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	conflicts := []Conflict{}
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		for i := range reservations {
+			blackout, err := findBlackout(tx, &reservations[i])
+			if err != nil {
+				return err
+			}
+			if blackout != nil {
+				conflicts = append(conflicts, Conflict{Index: i, Reservation: reservations[i],
+					Reason: "Blacked out: " + blackout.Reason})
+				continue
+			}
+			overlaps, err := countOverlaps(tx, &reservations[i])
+			if err != nil {
+				return err
+			}
+			if overlaps > 0 {
+				conflicts = append(conflicts, Conflict{Index: i, Reservation: reservations[i], Reason: "Already Reserved"})
+				continue
+			}
+			for j := 0; j < i; j++ {
+				if reservations[j].sharesTimeWith(&reservations[i]) {
+					conflicts = append(conflicts, Conflict{Index: i, Reservation: reservations[i],
+						Reason: fmt.Sprintf("Overlaps reservation %d of the batch", j)})
+					break
+				}
+			}
+		}
+		if len(conflicts) > 0 {
 			return errors.New("Already Reserved")
 		}
+
+		return tx.Create(&reservations).Error
+	})
+	if err != nil {
+		if err.Error() == "Already Reserved" {
+			return conflicts, err
+		}
+		log.Printf("Error: %+v", err)
+		return nil, err
 	}
 
-	err = database.DBConn.Create(&reservation).Error
+	return nil, nil
+}
+
+// ReleaseReservations removes the selected reservations all at once and returns the ids of the
+// removed ones, the ids and references without a reservation are skipped. Each of the matched
+// reservations removes a reservation with the same room, date, day, start and end if there is one.
+func ReleaseReservations(release Release) ([]uint, error) {
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	released := []uint{}
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		reservations := []Reservation{}
+		err := tx.Where("id IN ?", release.IDs).Find(&reservations).Error
+		if err != nil {
+			return err
+		}
+		if len(release.References) > 0 {
+			referenced := []Reservation{}
+			err = tx.Where("reference IN ?", release.References).Not(map[string]interface{}{"id": append([]uint{0}, release.IDs...)}).
+				Find(&referenced).Error
+			if err != nil {
+				return err
+			}
+			reservations = append(reservations, referenced...)
+		}
+		for _, res := range reservations {
+			released = append(released, res.ID)
+		}
+
+		for _, match := range release.Reservations {
+			found := []Reservation{}
+			err := tx.Where("room_name = ? AND date = ? AND day = ? AND starts_at = ? AND ends_at = ?",
+				match.RoomName, match.Date, match.Day, match.Start, match.End).
+				Not(map[string]interface{}{"id": append([]uint{0}, released...)}).
+				Limit(1).Find(&found).Error
+			if err != nil {
+				return err
+			}
+			if len(found) > 0 {
+				released = append(released, found[0].ID)
+			}
+		}
+		if len(released) == 0 {
+			return nil
+		}
+
+		return tx.Delete(&Reservation{}, released).Error
+	})
 	if err != nil {
 		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+
+	return released, nil
+}
+
+// SwapReservation replaces a reservation with another one at once, the old one is only
+// removed if the replacement can be made. The old reservation is found by its ID, or when
+// that is zero by its room, date, day, start and end.
+func SwapReservation(old *Reservation, replacement *Reservation) error {
+	err := replacement.Validate()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	err = database.DBConn.Transaction(func(tx *gorm.DB) error {
+		found := []Reservation{}
+		query := tx.Limit(1)
+		if old.ID != 0 {
+			query = query.Where("id = ?", old.ID)
+		} else {
+			query = query.Where("room_name = ? AND date = ? AND day = ? AND starts_at = ? AND ends_at = ?",
+				old.RoomName, old.Date, old.Day, old.Start, old.End)
+		}
+		err := query.Find(&found).Error
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return errors.New("Reservation does not exists")
+		}
+
+		blackout, err := findBlackout(tx, replacement)
+		if err != nil {
+			return err
+		}
+		if blackout != nil {
+			return errors.New("Room is blacked out")
+		}
+
+		// free the old time first, so the replacement can overlap it
+		err = tx.Delete(&Reservation{}, found[0].ID).Error
+		if err != nil {
+			return err
+		}
+
+		overlaps, err := countOverlaps(tx, replacement)
+		if err != nil {
+			return err
+		}
+		if overlaps > 0 {
+			return errors.New("Already Reserved")
+		}
+
+		replacement.ID = 0
+		return tx.Create(replacement).Error
+	})
+	if err != nil && err.Error() != "Already Reserved" && err.Error() != "Reservation does not exists" &&
+		err.Error() != "Room is blacked out" {
+		log.Printf("Error: %+v", err)
+	}
+
+	return err
+}
+
+// RemoveReservation permanently removes the reservation with the given id.
+func RemoveReservation(id uint) (*Reservation, error) {
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
+	reservation := &Reservation{}
+	err := database.DBConn.Where("id = ?", id).Limit(1).Find(reservation).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if reservation.ID == 0 {
+		return nil, errors.New("Reservation does not exists")
+	}
 
+	err = database.DBConn.Delete(&Reservation{}, id).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	return reservation, nil
 }
 
+// countOverlaps counts the reservations of the room that share time with the given one.
+func countOverlaps(tx *gorm.DB, reservation *Reservation) (int64, error) {
+	var overlaps int64
+	err := tx.Model(Reservation{}).
+		Where("room_name = ? AND day = ? AND starts_at < ? AND ends_at > ?",
+			reservation.RoomName, reservation.Day, reservation.End, reservation.Start).
+		Scopes(sharingDates(reservation.Date)).
+		Count(&overlaps).Error
+	return overlaps, err
+}
+
+// sharesTimeWith reports whether the reservations take place in the same room at the same time.
+func (reservation *Reservation) sharesTimeWith(other *Reservation) bool {
+	sameDate := reservation.Date == "" || other.Date == "" || reservation.Date == other.Date
+	return reservation.RoomName == other.RoomName && reservation.Day == other.Day && sameDate &&
+		reservation.Interval().Overlaps(other.Interval())
+}
+
+// GetAllReservations returns every reservation of every room, the past ones included.
 func GetAllReservations() ([]Reservation, error) {
 	reservations := []Reservation{}
 
@@ -86,107 +414,178 @@ func GetAllReservations() ([]Reservation, error) {
 	return reservations, nil
 }
 
-// GetAvailableHours returns list of hours for the given room if succesfful
-// Returns error if room not found , returns empty list if there is no available hours
-func GetAvailableHours(roomname string, day int) ([]string, error) {
-	var exists bool
-	err := database.DBConn.Model(Room{}).Select("count(*) > 0").Where("name = ?", roomname).Find(&exists).Error
+// sharingDates limits a query to the reservations that take place on the given date. For
+// an empty date, that is every week from now on, the weekly ones and the upcoming dated ones.
+func sharingDates(date string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if date == "" {
+			return db.Where("date = '' OR date >= ?", Today())
+		}
+		return db.Where("date = '' OR date = ?", date)
+	}
+}
+
+// GetAvailableHours returns the time of the given room that is free on the given day every
+// week from now on if successful
+// Returns error if room not found or the day is not 1 to 7, returns empty lists if there is no
+// available hours
+func GetAvailableHours(roomname string, day int) (*DayAvailability, error) {
+	room, err := findRoom(roomname)
 	if err != nil {
-		log.Printf("Error: %+v", err)
 		return nil, err
 	}
-	if !exists {
-		return nil, errors.New("Room does not exists")
+	day, err = dayOfDate("", day, "reservation")
+	if err != nil {
+		return nil, err
+	}
+
+	// get reservations and blackouts with the given name and day
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("room_name = ? AND day = ?", roomname, day).Scopes(sharingDates(""))
 	}
-	availableHours := []string{"9", "10", "11", "12", "13", "14", "15", "16", "17"}
-	// get reservations with the given name and day
 	reservations := []Reservation{}
+	err = database.DBConn.Scopes(scope).Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	reservations, err = withBlackouts(reservations, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return dayAvailability(room, day, reservations), nil
+}
 
-	err = database.DBConn.Where("room_name = ? AND day = ?",
-		roomname, day).Find(&reservations).Error
+// GetAllAvailableHours returns the time of the given room that is free every week from now on,
+// for every day of the week if successful
+// Returns error if room not found, returns empty lists if there is no available hours
+func GetAllAvailableHours(roomname string) (*WeeklyAvailability, error) {
+	room, err := findRoom(roomname)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("room_name = ?", roomname).Scopes(sharingDates(""))
+	}
+	reservations := []Reservation{}
+	err = database.DBConn.Scopes(scope).Find(&reservations).Error
 	if err != nil {
 		log.Printf("Error: %+v", err)
 		return nil, err
 	}
+	reservations, err = withBlackouts(reservations, scope)
+	if err != nil {
+		return nil, err
+	}
 
-	// for every reservation:
-	// block starting from it's hour, till it's hour+duration
-	// rest is available
+	byDay := make(map[int][]Reservation)
 	for _, res := range reservations {
+		byDay[res.Day] = append(byDay[res.Day], res)
+	}
 
-		hourIndex := getHourIndex(availableHours, strconv.Itoa(res.Hour))
-		if hourIndex == -1 {
-			return nil, errors.New("No available hours for reservation")
-		}
-		availableHours = append(availableHours[:hourIndex], availableHours[hourIndex+res.Duration:]...)
+	weekly := &WeeklyAvailability{RoomName: roomname}
+	for day := 1; day <= 7; day++ {
+		weekly.Days = append(weekly.Days, *dayAvailability(room, day, byDay[day]))
 	}
-	return availableHours, nil
+	return weekly, nil
 }
 
-// GetAvailableHours returns list of hours for the given room for weekly manner if succesful
-// Returns error if room not found , returns empty list if there is no available hours
-func GetAllAvailableHours(roomname string) (map[string][]string, error) {
-	var exists bool
-	result := make(map[string][]string)
-	err := database.DBConn.Model(Room{}).Select("count(*) > 0").Where("name = ?", roomname).Find(&exists).Error
+// GetDateAvailability returns the free time of the given room on the given date if successful
+// Returns error if room not found or the date is malformed
+func GetDateAvailability(roomname string, date string) (*DayAvailability, error) {
+	availability, err := GetRangeAvailability(roomname, date, date)
 	if err != nil {
-		log.Printf("Error: %+v", err)
 		return nil, err
 	}
-	if !exists {
-		return nil, errors.New("Room does not exists")
+	return &availability.Days[0], nil
+}
+
+// GetRangeAvailability returns the free time of the given room for every date from the first
+// to the last one, both included, if successful
+// Returns error if room not found or the range is malformed
+func GetRangeAvailability(roomname string, from string, to string) (*RangeAvailability, error) {
+	first, err := ParseDate(from)
+	if err != nil {
+		return nil, err
+	}
+	last, err := ParseDate(to)
+	if err != nil {
+		return nil, err
+	}
+	if last.Before(first) {
+		return nil, errors.New("end of the date range is before its start")
+	}
+	if last.Sub(first).Hours()/24 >= MaxRangeDays {
+		return nil, fmt.Errorf("date range cannot be longer than %d days", MaxRangeDays)
 	}
-	days := []string{"1", "2", "3", "4", "5", "6", "7"}
-	for _, day := range days {
-		availableHours := []string{"9", "10", "11", "12", "13", "14", "15", "16", "17"}
-		// get reservations with the given name and day
-		reservations := []Reservation{}
 
-		err = database.DBConn.Where("room_name = ? AND day = ?",
-			roomname, day).Find(&reservations).Error
-		if err != nil {
-			log.Printf("Error: %+v", err)
-			return nil, err
-		}
+	room, err := findRoom(roomname)
+	if err != nil {
+		return nil, err
+	}
 
-		// for every reservation:
-		// block starting from it's hour, till it's hour+duration
-		// rest is available
-		for _, res := range reservations {
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("room_name = ? AND (date = '' OR (date >= ? AND date <= ?))", roomname, from, to)
+	}
+	reservations := []Reservation{}
+	err = database.DBConn.Scopes(scope).Find(&reservations).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	reservations, err = withBlackouts(reservations, scope)
+	if err != nil {
+		return nil, err
+	}
 
-			hourIndex := getHourIndex(availableHours, strconv.Itoa(res.Hour))
-			if hourIndex == -1 {
-				return nil, errors.New("No available hours for reservation")
-			}
-			availableHours = append(availableHours[:hourIndex], availableHours[hourIndex+res.Duration:]...)
+	weekly := make(map[int][]Reservation)
+	dated := make(map[string][]Reservation)
+	for _, res := range reservations {
+		if res.Date == "" {
+			weekly[res.Day] = append(weekly[res.Day], res)
+		} else {
+			dated[res.Date] = append(dated[res.Date], res)
 		}
-		result["day "+day] = append(result["day "+day], availableHours...)
 	}
 
+	result := &RangeAvailability{RoomName: roomname, From: from, To: to}
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		day := Weekday(date)
+		key := date.Format(DateLayout)
+		availability := dayAvailability(room, day, append(dated[key], weekly[day]...))
+		availability.Date = key
+		result.Days = append(result.Days, *availability)
+	}
 	return result, nil
 }
 
-func getHourIndex(hourlist []string, hour string) int {
-
-	for i, v := range hourlist {
-		if v == hour {
-			return i
-		}
+// dayAvailability subtracts the reservations of a single day from the opening hours of the room.
+func dayAvailability(room *Room, day int, reservations []Reservation) *DayAvailability {
+	hours, open := room.OpeningHours.Hours(day)
+	if !open {
+		return &DayAvailability{RoomName: room.Name, Day: day, Closed: true, Free: []Interval{}, Slots: []Clock{}}
 	}
-	return -1
-}
 
-func contains(hours []string, hour int) bool {
+	busy := make([]Interval, 0, len(reservations))
+	for _, res := range reservations {
+		busy = append(busy, res.Interval())
+	}
+	free := Subtract(hours, busy)
 
-	for _, h := range hours {
-		k, err := strconv.Atoi(h)
-		if err != nil {
-			return false
-		}
+	return &DayAvailability{RoomName: room.Name, Day: day, Open: &hours, Free: free, Slots: Slots(free, SlotMinutes)}
+}
 
-		if k == hour {
-			return true
-		}
+func checkRoomExists(roomname string) error {
+	var exists bool
+	err := database.DBConn.Model(Room{}).Select("count(*) > 0").Where("name = ?", roomname).Find(&exists).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return err
+	}
+	if !exists {
+		return errors.New("Room does not exists")
 	}
-	return false
+	return nil
 }
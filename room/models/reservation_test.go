@@ -0,0 +1,133 @@
+package models
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDatabase points the models at an empty database of their own for the test.
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "room.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("cannot open test database: %v", err)
+	}
+	err = db.AutoMigrate(&Room{}, &Reservation{}, &Blackout{}, &Building{})
+	if err != nil {
+		t.Fatalf("cannot migrate test database: %v", err)
+	}
+
+	previous := database.DBConn
+	database.DBConn = db
+	t.Cleanup(func() {
+		database.DBConn = previous
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestCreateReservationConcurrentOverlaps(t *testing.T) {
+	useTestDatabase(t)
+
+	err := CreateRoom(&Room{Name: "A101"})
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	// every one of them shares 10:00-11:00 with all of the others
+	const attempts = 300
+	times := []Interval{
+		{Start: ClockOf(10, 0), End: ClockOf(11, 0)},
+		{Start: ClockOf(9, 0), End: ClockOf(11, 0)},
+		{Start: ClockOf(10, 0), End: ClockOf(12, 0)},
+		{Start: ClockOf(9, 0), End: ClockOf(12, 0)},
+	}
+
+	// start all of them at once
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make([]error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = CreateReservation(&Reservation{
+				RoomName: "A101",
+				Date:     "2030-01-07",
+				Start:    times[i%len(times)].Start,
+				End:      times[i%len(times)].End,
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	won := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case err.Error() != "Already Reserved":
+			t.Errorf("reservation %d: got error %q, want Already Reserved", i, err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d of the overlapping reservations were made, want exactly 1", won)
+	}
+
+	var saved int64
+	err = database.DBConn.Model(Reservation{}).Where("room_name = ?", "A101").Count(&saved).Error
+	if err != nil {
+		t.Fatalf("count reservations: %v", err)
+	}
+	if saved != 1 {
+		t.Errorf("%d reservations saved, want 1", saved)
+	}
+}
+
+func TestGetAvailableHoursDay(t *testing.T) {
+	useTestDatabase(t)
+
+	err := CreateRoom(&Room{Name: "A101"})
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	tests := []struct {
+		day     int
+		wantErr string
+	}{
+		{1, ""},
+		{7, ""},
+		{0, "day value of reservation should be 1 to 7"},
+		{8, "day value of reservation should be 1 to 7"},
+		{-1, "day value of reservation should be 1 to 7"},
+	}
+	for _, tt := range tests {
+		availability, err := GetAvailableHours("A101", tt.day)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("GetAvailableHours(day %d) error = %v, want %q", tt.day, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GetAvailableHours(day %d): %v", tt.day, err)
+		}
+		if availability.Closed || len(availability.Free) != 1 || availability.Free[0] != DefaultOpeningHours {
+			t.Errorf("GetAvailableHours(day %d) = %+v, want the default opening hours free", tt.day, availability)
+		}
+	}
+}
@@ -1,34 +1,91 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/yusufatalay/SocketProgramming/room/database"
 
 	"gorm.io/gorm"
 )
 
+// DefaultPageSize and MaxPageSize bound how many rooms a page of the listing holds.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 // A room only has a unique name on its own
 // how-ever it also has a "Has Many" relationship with the Reservations
+// Capacity is how many people fit in the room, zero when it is not known. Equipment lists
+// what the room has, such as projector, whiteboard or video-conf. OpeningHours is when the
+// room can be reserved, an empty schedule keeps the DefaultOpeningHours every day.
 type Room struct {
 	Name         string        `gorm:"primaryKey" json:"room_name"`
-	Reservations []Reservation `gorm:"foreignKey:RoomName;References:Name" json:"reservations"`
+	Capacity     int           `gorm:"not null;default:0" json:"capacity"`
+	Building     string        `gorm:"not null;default:''" json:"building"`
+	Floor        int           `gorm:"not null;default:0" json:"floor"`
+	Equipment    Tags          `gorm:"type:text;not null;default:''" json:"equipment"`
+	OpeningHours Schedule      `gorm:"type:text;not null;default:''" json:"opening_hours"`
+	Reservations []Reservation `gorm:"foreignKey:RoomName;References:Name" json:"reservations,omitempty"`
+}
+
+// RoomUpdate holds the attributes of a room to change, the nil ones are kept.
+type RoomUpdate struct {
+	Capacity     *int        `json:"capacity"`
+	Building     *string     `json:"building"`
+	Floor        *int        `json:"floor"`
+	Equipment    *[]string   `json:"equipment"`
+	OpeningHours *[]DayHours `json:"opening_hours"`
 }
 
-// There is no constraint for room creation, only error would be the situation where user
-// tries to create a room with an existing name, and we won't consider that case in here.
+// Apply changes the attributes of the room that are given in the update.
+func (update *RoomUpdate) Apply(room *Room) {
+	if update.Capacity != nil {
+		room.Capacity = *update.Capacity
+	}
+	if update.Building != nil {
+		room.Building = *update.Building
+	}
+	if update.Floor != nil {
+		room.Floor = *update.Floor
+	}
+	if update.Equipment != nil {
+		room.Equipment = *update.Equipment
+	}
+	if update.OpeningHours != nil {
+		room.OpeningHours = *update.OpeningHours
+	}
+}
+
+// Validate checks the attributes of the room, and makes a set of its equipment and a schedule
+// of its opening hours.
 func (room *Room) Validate() (err error) {
 
 	if room.Name == "" {
-		err = errors.New("room name cannot be empty")
+		return errors.New("room name cannot be empty")
+	}
+	if room.Capacity < 0 {
+		return errors.New("capacity cannot be negative")
+	}
+	room.Building = strings.TrimSpace(room.Building)
+	room.Equipment, err = NewTags(room.Equipment)
+	if err != nil {
+		return err
 	}
+	room.OpeningHours, err = NewSchedule(room.OpeningHours)
 	return
 }
 
 func (room *Room) BeforeCreate(tx *gorm.DB) (err error) {
 
 	err = room.Validate()
+	if err != nil {
+		return err
+	}
 
 	// check whether if same room already exists
 	var exists bool
@@ -43,6 +100,17 @@ func (room *Room) BeforeCreate(tx *gorm.DB) (err error) {
 		return errors.New("Room already exists")
 	}
 
+	// a room can only be put in a building that exists
+	if room.Building != "" {
+		exists, err = buildingExists(database.DBConn, room.Building)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("Building does not exists")
+		}
+	}
+
 	return
 }
 
@@ -78,17 +146,107 @@ func RemoveRoom(roomname string) error {
 	return nil
 }
 
-func GetRoom(name string) (*Room, error) {
+// UpdateRoom changes the attributes of the room that are given in the update. The opening
+// hours cannot leave out the reservations from now on, they should be cancelled or moved first.
+func UpdateRoom(name string, update *RoomUpdate) (*Room, error) {
+	// keep the reservations from changing while they are checked against the new hours
+	reservationLock.Lock()
+	defer reservationLock.Unlock()
+
 	room := &Room{}
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+		rooms := []Room{}
+		err := tx.Where("name = ?", name).Limit(1).Find(&rooms).Error
+		if err != nil {
+			return err
+		}
+		if len(rooms) == 0 {
+			return errors.New("Room does not exists")
+		}
+		*room = rooms[0]
+
+		update.Apply(room)
+		err = room.Validate()
+		if err != nil {
+			return err
+		}
 
-	err := database.DBConn.First(&room, name).Error
+		if update.Building != nil && room.Building != "" {
+			exists, err := buildingExists(tx, room.Building)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return errors.New("Building does not exists")
+			}
+		}
+
+		if update.OpeningHours != nil {
+			reservations := []Reservation{}
+			err = tx.Where("room_name = ?", room.Name).Scopes(sharingDates("")).Find(&reservations).Error
+			if err != nil {
+				return err
+			}
+			left := 0
+			for i := range reservations {
+				if !room.isOpen(&reservations[i]) {
+					left++
+				}
+			}
+			if left > 0 {
+				return fmt.Errorf("opening hours leave out %d reservations of the room, cancel or move them first", left)
+			}
+		}
+
+		return tx.Model(room).Select("capacity", "building", "floor", "equipment", "opening_hours").Updates(room).Error
+	})
 	if err != nil {
-		log.Printf("Error: %+v", err)
+		if err.Error() != "Room does not exists" && err.Error() != "Building does not exists" {
+			log.Printf("Error: %+v", err)
+		}
 		return nil, err
 	}
 	return room, nil
 }
 
+// isOpen reports whether the room is open for the whole time of the reservation.
+func (room *Room) isOpen(reservation *Reservation) bool {
+	hours, open := room.OpeningHours.Hours(reservation.Day)
+	return open && hours.Contains(reservation.Interval())
+}
+
+// findRoom returns the room with the given name without its reservations.
+func findRoom(name string) (*Room, error) {
+	rooms := []Room{}
+	err := database.DBConn.Where("name = ?", name).Limit(1).Find(&rooms).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if len(rooms) == 0 {
+		return nil, errors.New("Room does not exists")
+	}
+	return &rooms[0], nil
+}
+
+// GetRoom returns the room with the given name along with its reservations in the order they
+// take place, the weekly ones first.
+func GetRoom(name string) (*Room, error) {
+	rooms := []Room{}
+
+	err := database.DBConn.Preload("Reservations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date, day, starts_at")
+	}).Where("name = ?", name).Limit(1).Find(&rooms).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	if len(rooms) == 0 {
+		return nil, errors.New("Room does not exists")
+	}
+	return &rooms[0], nil
+}
+
 func GetAllRooms() ([]Room, error) {
 	rooms := []Room{}
 
@@ -99,3 +257,42 @@ func GetAllRooms() ([]Room, error) {
 	}
 	return rooms, nil
 }
+
+// RoomPage is a page of the rooms ordered by their names, NextCursor is empty on the last page.
+type RoomPage struct {
+	Rooms      []Room `json:"rooms"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// FindRooms returns a page of the rooms whose names contain the given text, an empty text
+// matches every room. Cursor is the NextCursor of the previous page.
+func FindRooms(name string, cursor string, limit int) (*RoomPage, error) {
+	db := database.DBConn.Order("name")
+	if name != "" {
+		// the wildcards of LIKE are taken as they are
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(name)
+		db = db.Where(`name LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+	}
+	if cursor != "" {
+		last, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, errors.New("cursor is not one of this listing")
+		}
+		db = db.Where("name > ?", string(last))
+	}
+
+	// one more than the page tells if there is a next page
+	rooms := []Room{}
+	err := db.Limit(limit + 1).Find(&rooms).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+
+	page := &RoomPage{Rooms: rooms}
+	if len(rooms) > limit {
+		page.Rooms = rooms[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Rooms[limit-1].Name))
+	}
+	return page, nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DayHours is the time a room is open on a day of the week, 1 for Monday through 7 for Sunday.
+type DayHours struct {
+	Day   int   `json:"day"`
+	Start Clock `json:"start"`
+	End   Clock `json:"end"`
+}
+
+// Schedule is the weekly opening hours of a room, ordered by day. A room is closed on the days
+// its schedule does not have, a room without a schedule is open in DefaultOpeningHours every day.
+// It is stored as a comma separated list such as "1=08:00-18:00,2=08:00-18:00".
+type Schedule []DayHours
+
+// NewSchedule checks the opening hours and returns them as a schedule, every day can have a
+// single time range.
+func NewSchedule(days []DayHours) (Schedule, error) {
+	schedule := Schedule{}
+	seen := map[int]bool{}
+	for _, hours := range days {
+		if hours.Day < 1 || hours.Day > 7 {
+			return nil, errors.New("day value of opening hours should be 1 to 7")
+		}
+		if seen[hours.Day] {
+			return nil, fmt.Errorf("day %d has opening hours more than once", hours.Day)
+		}
+		if hours.End <= hours.Start {
+			return nil, fmt.Errorf("opening hours of day %d should end after they start", hours.Day)
+		}
+		if hours.End > EndOfDay {
+			return nil, fmt.Errorf("opening hours of day %d cannot end after 24:00", hours.Day)
+		}
+		seen[hours.Day] = true
+		schedule = append(schedule, hours)
+	}
+	sort.Slice(schedule, func(i, j int) bool {
+		return schedule[i].Day < schedule[j].Day
+	})
+	return schedule, nil
+}
+
+// ParseSchedule reads a schedule from a comma separated list of days and their hours, a range
+// of days can share their hours, e.g. "1-5=08:00-18:00,6=10:00-14:00".
+func ParseSchedule(list string) (Schedule, error) {
+	days := []DayHours{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dayPart, timePart, ok := strings.Cut(entry, "=")
+		startPart, endPart, ok2 := strings.Cut(timePart, "-")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("opening hours %q should look like 1-5=08:00-18:00", entry)
+		}
+		start, err := ParseClock(startPart)
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParseClock(endPart)
+		if err != nil {
+			return nil, err
+		}
+
+		firstPart, lastPart, isRange := strings.Cut(dayPart, "-")
+		first, err := strconv.Atoi(firstPart)
+		if err != nil {
+			return nil, fmt.Errorf("opening hours %q should look like 1-5=08:00-18:00", entry)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(lastPart)
+			if err != nil {
+				return nil, fmt.Errorf("opening hours %q should look like 1-5=08:00-18:00", entry)
+			}
+			if first > last {
+				return nil, fmt.Errorf("opening hours %q should have the first day of its range before the last one", entry)
+			}
+		}
+		for day := first; day <= last; day++ {
+			days = append(days, DayHours{Day: day, Start: start, End: end})
+		}
+	}
+	return NewSchedule(days)
+}
+
+// Hours returns the opening hours of the day, and false when the room is closed that day or
+// the day is not 1 to 7.
+func (schedule Schedule) Hours(day int) (Interval, bool) {
+	if day < 1 || day > 7 {
+		return Interval{}, false
+	}
+	if len(schedule) == 0 {
+		return DefaultOpeningHours, true
+	}
+	for _, hours := range schedule {
+		if hours.Day == day {
+			return Interval{Start: hours.Start, End: hours.End}, true
+		}
+	}
+	return Interval{}, false
+}
+
+// String is the human readable list of the opening hours.
+func (schedule Schedule) String() string {
+	if len(schedule) == 0 {
+		return fmt.Sprintf("every day %s-%s", DefaultOpeningHours.Start, DefaultOpeningHours.End)
+	}
+	days := make([]string, 0, len(schedule))
+	for _, hours := range schedule {
+		days = append(days, fmt.Sprintf("day %d %s-%s", hours.Day, hours.Start, hours.End))
+	}
+	return strings.Join(days, ", ")
+}
+
+// Value stores the schedule in the database.
+func (schedule Schedule) Value() (driver.Value, error) {
+	days := make([]string, 0, len(schedule))
+	for _, hours := range schedule {
+		days = append(days, fmt.Sprintf("%d=%s-%s", hours.Day, hours.Start, hours.End))
+	}
+	return strings.Join(days, ","), nil
+}
+
+// Scan reads the schedule from the database.
+func (schedule *Schedule) Scan(value interface{}) error {
+	var list string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		list = v
+	case []byte:
+		list = string(v)
+	default:
+		return fmt.Errorf("cannot read opening hours from %T", value)
+	}
+
+	parsed, err := ParseSchedule(list)
+	if err != nil {
+		return err
+	}
+	*schedule = parsed
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    Schedule
+		wantErr bool
+	}{
+		{"empty", "", Schedule{}, false},
+		{"single day", "3=08:00-18:00", Schedule{{Day: 3, Start: ClockOf(8, 0), End: ClockOf(18, 0)}}, false},
+		{"range of days", "1-3=08:00-12:00", Schedule{
+			{Day: 1, Start: ClockOf(8, 0), End: ClockOf(12, 0)},
+			{Day: 2, Start: ClockOf(8, 0), End: ClockOf(12, 0)},
+			{Day: 3, Start: ClockOf(8, 0), End: ClockOf(12, 0)},
+		}, false},
+		{"ordered by day", "6=10:00-14:00,2=08:00-24:00", Schedule{
+			{Day: 2, Start: ClockOf(8, 0), End: EndOfDay},
+			{Day: 6, Start: ClockOf(10, 0), End: ClockOf(14, 0)},
+		}, false},
+		{"reversed range", "5-1=08:00-18:00", nil, true},
+		{"day out of range", "0-2=08:00-18:00", nil, true},
+		{"day given twice", "1-2=08:00-12:00,2=13:00-18:00", nil, true},
+		{"ends before it starts", "1=18:00-08:00", nil, true},
+		{"missing hours", "1-5", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedule(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSchedule(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleHours(t *testing.T) {
+	weekdays, _ := NewSchedule([]DayHours{{Day: 1, Start: ClockOf(8, 0), End: ClockOf(18, 0)}})
+	tests := []struct {
+		name     string
+		schedule Schedule
+		day      int
+		want     Interval
+		wantOpen bool
+	}{
+		{"day of the schedule", weekdays, 1, Interval{Start: ClockOf(8, 0), End: ClockOf(18, 0)}, true},
+		{"day missing from the schedule", weekdays, 2, Interval{}, false},
+		{"default opening hours", Schedule{}, 7, DefaultOpeningHours, true},
+		{"day zero", Schedule{}, 0, Interval{}, false},
+		{"day after Sunday", Schedule{}, 8, Interval{}, false},
+		{"negative day", weekdays, -1, Interval{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, open := tt.schedule.Hours(tt.day)
+			if got != tt.want || open != tt.wantOpen {
+				t.Errorf("Hours(%d) = %v, %v, want %v, %v", tt.day, got, open, tt.want, tt.wantOpen)
+			}
+		})
+	}
+}
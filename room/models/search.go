@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/yusufatalay/SocketProgramming/room/database"
+
+	"gorm.io/gorm"
+)
+
+// RoomSearch is a time window and what a room should have to be found for it. The window is
+// on a Date, or on a Day of the week every week, from Start to End, which may be given in
+// whole hours with Hour and Duration instead. Capacity is the least number of people the room
+// should fit, and Equipment what it should have. The search is limited to the rooms of a
+// Building, and to one of its floors when a Floor is given as well.
+type RoomSearch struct {
+	Date      string   `json:"date,omitempty"`
+	Day       int      `json:"day"`
+	Start     Clock    `json:"start"`
+	End       Clock    `json:"end"`
+	Hour      int      `json:"hour,omitempty"`
+	Duration  int      `json:"duration,omitempty"`
+	Capacity  int      `json:"capacity,omitempty"`
+	Equipment []string `json:"equipment,omitempty"`
+	Building  string   `json:"building,omitempty"`
+	Floor     *int     `json:"floor,omitempty"`
+}
+
+// RoomSearchResult is the window that was searched and the rooms found for it, the best
+// fitting first.
+type RoomSearchResult struct {
+	Date  string `json:"date,omitempty"`
+	Day   int    `json:"day"`
+	Start Clock  `json:"start"`
+	End   Clock  `json:"end"`
+	Rooms []Room `json:"rooms"`
+}
+
+// SearchRooms returns the rooms that have what the search asks for and are free for its whole
+// window, open and neither reserved nor blacked out. The ones with the fewest seats beyond the
+// capacity asked for fit best, then the ones with the least equipment beyond what was asked for.
+// A room whose capacity is not known is only found when no capacity is asked for.
+func SearchRooms(search *RoomSearch) (*RoomSearchResult, error) {
+	window := &Reservation{
+		Date:     search.Date,
+		Day:      search.Day,
+		Start:    search.Start,
+		End:      search.End,
+		Hour:     search.Hour,
+		Duration: search.Duration,
+	}
+	err := window.validateTime()
+	if err != nil {
+		return nil, err
+	}
+	if search.Capacity < 0 {
+		return nil, errors.New("capacity cannot be negative")
+	}
+	equipment, err := NewTags(search.Equipment)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.DBConn.Model(Room{})
+	if search.Building != "" {
+		exists, err := buildingExists(database.DBConn, search.Building)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("Building does not exists")
+		}
+		db = db.Where("building = ?", search.Building)
+	}
+	if search.Floor != nil {
+		if search.Building == "" {
+			return nil, errors.New("floor can only be searched within a building")
+		}
+		db = db.Where("floor = ?", *search.Floor)
+	}
+	if search.Capacity > 0 {
+		db = db.Where("capacity >= ?", search.Capacity)
+	}
+	for _, tag := range equipment {
+		db = db.Where("equipment LIKE ?", "%,"+tag+",%")
+	}
+
+	// the rooms with a reservation or a blackout sharing time with the window
+	sharingWindow := func(db *gorm.DB) *gorm.DB {
+		return db.Select("room_name").
+			Where("day = ? AND starts_at < ? AND ends_at > ?", window.Day, window.End, window.Start).
+			Scopes(sharingDates(window.Date))
+	}
+	busy := database.DBConn.Model(Reservation{}).Scopes(sharingWindow)
+	blackedOut := database.DBConn.Model(Blackout{}).Scopes(sharingWindow)
+
+	found := []Room{}
+	err = db.Where("name NOT IN (?) AND name NOT IN (?)", busy, blackedOut).Find(&found).Error
+	if err != nil {
+		log.Printf("Error: %+v", err)
+		return nil, err
+	}
+	// and open for the whole window
+	rooms := []Room{}
+	for i := range found {
+		if found[i].isOpen(window) {
+			rooms = append(rooms, found[i])
+		}
+	}
+
+	sort.SliceStable(rooms, func(i, j int) bool {
+		if rooms[i].Capacity != rooms[j].Capacity {
+			return rooms[i].Capacity < rooms[j].Capacity
+		}
+		if len(rooms[i].Equipment) != len(rooms[j].Equipment) {
+			return len(rooms[i].Equipment) < len(rooms[j].Equipment)
+		}
+		return rooms[i].Name < rooms[j].Name
+	})
+
+	return &RoomSearchResult{
+		Date:  window.Date,
+		Day:   window.Day,
+		Start: window.Start,
+		End:   window.End,
+		Rooms: rooms,
+	}, nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SlotMinutes is the granularity reservations are made in, their start and end are
+// multiples of it. It is 60 unless the deployment configures another one.
+var SlotMinutes = 60
+
+// SetSlotMinutes configures the granularity of the reservations from its setting, which
+// should be one of 15, 30 or 60.
+func SetSlotMinutes(setting string) error {
+	minutes, err := strconv.Atoi(setting)
+	if err != nil || (minutes != 15 && minutes != 30 && minutes != 60) {
+		return fmt.Errorf("slot size should be 15, 30 or 60 minutes, not %q", setting)
+	}
+	SlotMinutes = minutes
+	return nil
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tagPattern is what a tag looks like, e.g. projector or video-conf.
+var tagPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Tags is a set of labels such as the equipment of a room, sorted and without duplicates.
+// It is stored as a comma separated list with a comma on both ends, e.g. ",projector,whiteboard,",
+// so the rows having a tag can be found with LIKE '%,projector,%'.
+type Tags []string
+
+// NewTags returns the tags as a set, their case and surrounding spaces do not matter.
+func NewTags(tags []string) (Tags, error) {
+	set := Tags{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("tag %q should only have letters, digits and dashes", tag)
+		}
+		seen[tag] = true
+		set = append(set, tag)
+	}
+	sort.Strings(set)
+	return set, nil
+}
+
+// ParseTags reads tags from a comma separated list.
+func ParseTags(list string) (Tags, error) {
+	return NewTags(strings.Split(list, ","))
+}
+
+// Has reports whether the tag is one of the tags.
+func (tags Tags) Has(tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// String is the comma separated list of the tags.
+func (tags Tags) String() string {
+	return strings.Join(tags, ", ")
+}
+
+// Value stores the tags in the database.
+func (tags Tags) Value() (driver.Value, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	return "," + strings.Join(tags, ",") + ",", nil
+}
+
+// Scan reads the tags from the database.
+func (tags *Tags) Scan(value interface{}) error {
+	var list string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		list = v
+	case []byte:
+		list = string(v)
+	default:
+		return fmt.Errorf("cannot read tags from %T", value)
+	}
+
+	*tags = Tags{}
+	for _, tag := range strings.Split(list, ",") {
+		if tag != "" {
+			*tags = append(*tags, tag)
+		}
+	}
+	return nil
+}